@@ -10,6 +10,8 @@ import (
 
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
@@ -17,7 +19,9 @@ import (
 	appconfig "github.com/nfelsen/draino2/internal/config"
 	"github.com/nfelsen/draino2/internal/controller"
 	"github.com/nfelsen/draino2/internal/drainer"
+	"github.com/nfelsen/draino2/internal/hooks"
 	"github.com/nfelsen/draino2/internal/metrics"
+	"github.com/nfelsen/draino2/internal/types"
 )
 
 func main() {
@@ -63,24 +67,63 @@ func main() {
 	metrics := metrics.NewMetrics()
 
 	// Create drainer
+	if err := drainer.ValidatePodFilters(cfg.DrainSettings.PodFilters); err != nil {
+		log.Error(err, "invalid drain settings")
+		os.Exit(1)
+	}
+	if err := drainer.ValidateCordonMode(drainer.CordonMode(cfg.DrainSettings.CordonMode)); err != nil {
+		log.Error(err, "invalid drain settings")
+		os.Exit(1)
+	}
+	if err := drainer.ValidateEscalationPolicy(drainer.EscalationPolicy(cfg.DrainSettings.EvictionFallback)); err != nil {
+		log.Error(err, "invalid drain settings")
+		os.Exit(1)
+	}
 	drainerConfig := &drainer.DrainerConfig{
-		GracePeriod:        cfg.DrainSettings.MaxGracePeriod,
-		Timeout:            cfg.DrainSettings.DrainBuffer,
-		Force:              cfg.DrainSettings.EvictUnreplicatedPods,
-		IgnoreDaemonSets:   !cfg.DrainSettings.EvictDaemonSetPods,
-		DeleteEmptyDirData: cfg.DrainSettings.EvictLocalStoragePods,
-		PodSelector:        nil, // TODO: Add pod selector configuration
+		GracePeriod:              cfg.DrainSettings.MaxGracePeriod,
+		DryRun:                   cfg.DryRun,
+		EvictionHeadroom:         cfg.DrainSettings.EvictionHeadroom,
+		EscalationPolicy:         drainer.EscalationPolicy(cfg.DrainSettings.EvictionFallback),
+		Timeout:                  cfg.DrainSettings.DrainBuffer,
+		Force:                    cfg.DrainSettings.ContinueOnEvictionFailure,
+		EvictUnreplicatedPods:    cfg.DrainSettings.EvictUnreplicatedPods,
+		BlockOnUnreplicatedPods:  cfg.DrainSettings.BlockOnUnreplicatedPods,
+		IgnoreDaemonSets:         !cfg.DrainSettings.EvictDaemonSetPods,
+		DeleteEmptyDirData:       cfg.DrainSettings.EvictLocalStoragePods,
+		DeleteMemoryEmptyDirData: cfg.DrainSettings.EvictMemoryEmptyDirPods,
+		EvictHostPathPods:        cfg.DrainSettings.EvictHostPathPods,
+		EvictLocalPVPods:         cfg.DrainSettings.EvictLocalPVPods,
+		PodSelector:              nil, // TODO: Add pod selector configuration
+		PodFilters:               cfg.DrainSettings.PodFilters,
+		EvictionTiers:            evictionTiers(cfg.DrainSettings.EvictionTiers),
+		MaxConcurrentEvictions:   cfg.DrainSettings.MaxConcurrentEvictions,
+		StatefulSetReadyTimeout:  cfg.DrainSettings.StatefulSetReadyTimeout,
+		RollingEviction:          cfg.DrainSettings.RollingEviction,
+		RollingEvictionTimeout:   cfg.DrainSettings.RollingEvictionTimeout,
+		VolumeDetachTimeout:      cfg.DrainSettings.VolumeDetachTimeout,
+		CordonMode:               drainer.CordonMode(cfg.DrainSettings.CordonMode),
+		CordonTaint:              cordonTaint(cfg.DrainSettings.CordonTaint),
+		CordonNoExecute:          cfg.DrainSettings.CordonTaint.NoExecute,
+		RetryBackoff:             cfg.DrainSettings.EvictionRetry.InitialBackoff,
+		MaxRetryBackoff:          cfg.DrainSettings.EvictionRetry.MaxBackoff,
+		RetryBackoffFactor:       cfg.DrainSettings.EvictionRetry.Factor,
 	}
-	drainer := drainer.NewDrainer(kubeClient, mgr.GetEventRecorderFor("draino2"), drainerConfig)
+	drainer := drainer.NewDrainer(kubeClient, mgr.GetEventRecorderFor("draino2"), metrics, drainerConfig)
 
 	// Create and register controller
+	if err := hooks.ValidateHooks(cfg.Hooks); err != nil {
+		log.Error(err, "invalid hooks")
+		os.Exit(1)
+	}
 	drainController := &controller.DrainController{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("draino2"),
-		Config:   &cfg,
-		Drainer:  drainer,
-		Metrics:  metrics,
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("draino2"),
+		Config:     &cfg,
+		Drainer:    drainer,
+		Metrics:    metrics,
+		Hooks:      hooks.NewRunner(cfg.Hooks),
+		InstanceID: string(uuid.NewUUID()),
 	}
 
 	if err := drainController.SetupWithManager(mgr); err != nil {
@@ -128,3 +171,27 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// evictionTiers converts the configured eviction tiers for the drainer
+func evictionTiers(tiers []types.EvictionTier) []drainer.EvictionTier {
+	result := make([]drainer.EvictionTier, 0, len(tiers))
+	for _, tier := range tiers {
+		result = append(result, drainer.EvictionTier{
+			Name:               tier.Name,
+			PriorityClassNames: tier.PriorityClassNames,
+			MinPriority:        tier.MinPriority,
+			MaxPriority:        tier.MaxPriority,
+			OwnerKinds:         tier.OwnerKinds,
+		})
+	}
+	return result
+}
+
+// cordonTaint converts the configured cordon taint for the drainer
+func cordonTaint(taint types.CordonTaint) corev1.Taint {
+	return corev1.Taint{
+		Key:    taint.Key,
+		Value:  taint.Value,
+		Effect: corev1.TaintEffect(taint.Effect),
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -13,6 +14,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/nfelsen/draino2/internal/drainer"
@@ -61,6 +63,8 @@ func (s *Server) setupRoutes() {
 	// Node management
 	apiV1.HandleFunc("/nodes", s.listNodes).Methods("GET")
 	apiV1.HandleFunc("/nodes/{name}/drain", s.drainNode).Methods("POST")
+	apiV1.HandleFunc("/nodes/{name}/drain", s.cancelDrain).Methods("DELETE")
+	apiV1.HandleFunc("/nodes/{name}/plan", s.getDrainPlan).Methods("GET")
 	apiV1.HandleFunc("/nodes/{name}/cordon", s.cordonNode).Methods("POST")
 	apiV1.HandleFunc("/nodes/{name}/uncordon", s.uncordonNode).Methods("POST")
 	apiV1.HandleFunc("/nodes/{name}", s.getNode).Methods("GET")
@@ -165,6 +169,22 @@ func (s *Server) drainNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// In dry-run mode return the plan instead of draining
+	if s.config.DryRun {
+		plan, err := s.drainer.Plan(r.Context(), node, !s.config.DrainSettings.SkipCordon)
+		if err != nil {
+			s.logger.Error("Failed to plan drain", zap.String("node", nodeName), zap.Error(err))
+			http.Error(w, fmt.Sprintf("Failed to plan drain: %v", err), http.StatusInternalServerError)
+			return
+		}
+		s.drainer.RecordPlan(r.Context(), node, plan)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(plan)
+		return
+	}
+
 	// Perform drain operation
 	if err := s.drainer.Drain(r.Context(), node); err != nil {
 		s.logger.Error("Failed to drain node", zap.String("node", nodeName), zap.Error(err))
@@ -180,6 +200,37 @@ func (s *Server) drainNode(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// getDrainPlan returns the last drain plan computed for a node. Passing
+// refresh=true, or asking for a node without a plan, computes a new one.
+func (s *Server) getDrainPlan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nodeName := vars["name"]
+
+	plan, ok := s.drainer.LastPlan(nodeName)
+	if !ok || r.URL.Query().Get("refresh") == "true" {
+		node, err := s.client.CoreV1().Nodes().Get(r.Context(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				http.Error(w, "Node not found", http.StatusNotFound)
+				return
+			}
+			s.logger.Error("Failed to get node", zap.String("node", nodeName), zap.Error(err))
+			http.Error(w, "Failed to get node", http.StatusInternalServerError)
+			return
+		}
+
+		plan, err = s.drainer.Plan(r.Context(), node, !s.config.DrainSettings.SkipCordon)
+		if err != nil {
+			s.logger.Error("Failed to plan drain", zap.String("node", nodeName), zap.Error(err))
+			http.Error(w, fmt.Sprintf("Failed to plan drain: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
 // cordonNode manually cordons a node
 func (s *Server) cordonNode(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -229,8 +280,14 @@ func (s *Server) uncordonNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Perform uncordon operation
-	if err := s.drainer.Uncordon(r.Context(), node); err != nil {
+	// Perform uncordon operation; force=true also reopens nodes draino2 did not cordon
+	force := r.URL.Query().Get("force") == "true"
+	if err := s.drainer.Uncordon(r.Context(), node, force); err != nil {
+		var notOwnedErr *drainer.CordonNotOwnedError
+		if stderrors.As(err, &notOwnedErr) {
+			http.Error(w, notOwnedErr.Error(), http.StatusConflict)
+			return
+		}
 		s.logger.Error("Failed to uncordon node", zap.String("node", nodeName), zap.Error(err))
 		http.Error(w, fmt.Sprintf("Failed to uncordon node: %v", err), http.StatusInternalServerError)
 		return
@@ -244,6 +301,54 @@ func (s *Server) uncordonNode(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// cancelDrain asks the controller to cancel an in-progress drain by setting
+// the drain-cancel annotation, which the drain watches for
+func (s *Server) cancelDrain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	nodeName := vars["name"]
+
+	// Get the node
+	node, err := s.client.CoreV1().Nodes().Get(r.Context(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			http.Error(w, "Node not found", http.StatusNotFound)
+			return
+		}
+		s.logger.Error("Failed to get node", zap.String("node", nodeName), zap.Error(err))
+		http.Error(w, "Failed to get node", http.StatusInternalServerError)
+		return
+	}
+
+	if !s.isNodeBeingDrained(node) {
+		http.Error(w, "Node is not being drained", http.StatusConflict)
+		return
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				"draino2.kubernetes.io/drain-cancel": time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		http.Error(w, "Failed to build patch", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.client.CoreV1().Nodes().Patch(r.Context(), nodeName, apitypes.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		s.logger.Error("Failed to request drain cancellation", zap.String("node", nodeName), zap.Error(err))
+		http.Error(w, fmt.Sprintf("Failed to cancel drain: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": fmt.Sprintf("Cancellation of drain requested for node %s", nodeName),
+		"node":    nodeName,
+	})
+}
+
 // isNodeBeingDrained checks if a node is currently being drained
 func (s *Server) isNodeBeingDrained(node *corev1.Node) bool {
 	if node.Annotations == nil {
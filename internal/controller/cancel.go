@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cancelPollInterval is how often an in-progress drain checks whether it was cancelled
+var cancelPollInterval = 5 * time.Second
+
+// drainCancelledError is the cause of a drain context that was cancelled on request
+type drainCancelledError struct {
+	reason string
+}
+
+// Error implements the error interface
+func (e *drainCancelledError) Error() string {
+	return "drain cancelled: " + e.reason
+}
+
+// startDrain gives a node's drain its own cancellable context and watches the
+// node for cancellation requests and stuck drains until the returned function
+// is called. A drain started by a trigger label is cancelled when the label
+// is removed.
+func (r *DrainController) startDrain(ctx context.Context, nodeName string, labelTriggered bool) (context.Context, func()) {
+	drainCtx, cancel := context.WithCancelCause(ctx)
+
+	r.drainsLock.Lock()
+	if r.drains == nil {
+		r.drains = make(map[string]context.CancelCauseFunc)
+	}
+	r.drains[nodeName] = cancel
+	r.drainsLock.Unlock()
+
+	go r.watchDrain(drainCtx, nodeName, labelTriggered)
+
+	return drainCtx, func() {
+		r.drainsLock.Lock()
+		delete(r.drains, nodeName)
+		r.drainsLock.Unlock()
+		cancel(nil)
+	}
+}
+
+// isDrainActive reports whether this process is currently draining the node
+func (r *DrainController) isDrainActive(nodeName string) bool {
+	r.drainsLock.Lock()
+	defer r.drainsLock.Unlock()
+	_, ok := r.drains[nodeName]
+	return ok
+}
+
+// CancelDrain stops an in-progress drain of the node. It reports whether a
+// drain was running.
+func (r *DrainController) CancelDrain(nodeName, reason string) bool {
+	return r.stopDrain(nodeName, &drainCancelledError{reason: reason})
+}
+
+// stopDrain cancels an in-progress drain of the node with the given cause
+func (r *DrainController) stopDrain(nodeName string, cause error) bool {
+	r.drainsLock.Lock()
+	defer r.drainsLock.Unlock()
+
+	cancel, ok := r.drains[nodeName]
+	if ok {
+		cancel(cause)
+	}
+	return ok
+}
+
+// watchDrain refreshes the drain's heartbeat and stops the drain when the node gets the drain-cancel
+// annotation, when the trigger label that started a label-triggered drain is
+// removed, or when it has been draining for longer than the maximum drain
+// duration. Drains started by a node condition are not cancelled when the
+// condition clears, since evicting the pods is often what clears it.
+func (r *DrainController) watchDrain(ctx context.Context, nodeName string, labelTriggered bool) {
+	log := klog.FromContext(ctx)
+
+	_ = wait.PollUntilContextCancel(ctx, cancelPollInterval, false, func(ctx context.Context) (bool, error) {
+		node := &corev1.Node{}
+		if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+			log.V(2).Info("Failed to get node while watching for cancellation", "node", nodeName, "error", err)
+			return false, nil
+		}
+
+		if _, ok := node.Annotations["draino2.kubernetes.io/drain-cancel"]; ok {
+			return r.CancelDrain(nodeName, "drain-cancel annotation was set"), nil
+		}
+		if _, matched := matchLabels(r.triggers, node); labelTriggered && !matched {
+			return r.CancelDrain(nodeName, "trigger label was removed"), nil
+		}
+		if stuck := r.checkDrainStuck(node, time.Now()); stuck != nil {
+			return r.stopDrain(nodeName, stuck), nil
+		}
+		if err := r.refreshHeartbeat(ctx, node, time.Now()); err != nil {
+			log.V(2).Info("Failed to refresh drain heartbeat", "node", nodeName, "error", err)
+		}
+		return false, nil
+	})
+}
+
+// handleDrainCancelled cleans up after a cancelled drain: it clears the drain
+// annotations, marks the node so the drain is not restarted while its trigger
+// is still present, and optionally uncordons it
+func (r *DrainController) handleDrainCancelled(ctx context.Context, nodeName string, cancelled *drainCancelledError) (ctrl.Result, error) {
+	log := klog.FromContext(ctx)
+	log.Info("Drain cancelled", "node", nodeName, "reason", cancelled.reason)
+
+	node := &corev1.Node{}
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	delete(node.Annotations, "draino2.kubernetes.io/drain-in-progress")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-start-time")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-owner")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-heartbeat")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-cancel")
+	node.Annotations["draino2.kubernetes.io/drain-cancelled"] = time.Now().UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, node, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to clean up cancelled drain: %w", err)
+	}
+
+	if r.Config.DrainSettings.UncordonOnCancel {
+		if err := r.Drainer.Uncordon(ctx, node, false); err != nil {
+			log.Error(err, "Failed to uncordon node after cancelled drain", "node", nodeName)
+		}
+	}
+
+	r.Recorder.Eventf(node, corev1.EventTypeNormal, "DrainCancelled",
+		"Drain of node %s cancelled: %s", nodeName, cancelled.reason)
+	if r.Metrics != nil {
+		r.Metrics.DrainOperationsCancelled.Inc()
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// isDrainCancelled checks if a drain of the node was cancelled, or a
+// cancellation was requested before it started
+func (r *DrainController) isDrainCancelled(node *corev1.Node) bool {
+	_, cancelled := node.Annotations["draino2.kubernetes.io/drain-cancelled"]
+	_, requested := node.Annotations["draino2.kubernetes.io/drain-cancel"]
+	return cancelled || requested
+}
+
+// clearDrainCancelled removes the cancellation annotations once the node no
+// longer matches a drain trigger, so that a new trigger drains it again
+func (r *DrainController) clearDrainCancelled(ctx context.Context, node *corev1.Node) error {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	delete(node.Annotations, "draino2.kubernetes.io/drain-cancelled")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-cancel")
+
+	return r.Patch(ctx, node, patch)
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/nfelsen/draino2/internal/drainer"
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+// drainCancelCause waits briefly for a drain context to be cancelled and
+// returns the cancellation cause, or nil if the drain is still running
+func drainCancelCause(ctx context.Context) *drainCancelledError {
+	select {
+	case <-ctx.Done():
+	case <-time.After(200 * time.Millisecond):
+		return nil
+	}
+	var cancelled *drainCancelledError
+	if stderrors.As(context.Cause(ctx), &cancelled) {
+		return cancelled
+	}
+	return nil
+}
+
+func TestWatchDrain(t *testing.T) {
+	cancelPollInterval = 10 * time.Millisecond
+
+	config := &types.Config{
+		LabelTriggers:  []types.LabelTrigger{{Key: "drain", Value: "true"}},
+		NodeConditions: []types.NodeCondition{{Type: corev1.NodeMemoryPressure}},
+	}
+
+	tests := []struct {
+		name           string
+		labelTriggered bool
+		update         func(node *corev1.Node)
+		expected       string
+	}{
+		{
+			name:           "trigger label removed",
+			labelTriggered: true,
+			update:         func(node *corev1.Node) { delete(node.Labels, "drain") },
+			expected:       "trigger label was removed",
+		},
+		{
+			name:           "cancel annotation",
+			labelTriggered: true,
+			update: func(node *corev1.Node) {
+				node.Annotations = map[string]string{"draino2.kubernetes.io/drain-cancel": "true"}
+			},
+			expected: "drain-cancel annotation was set",
+		},
+		{
+			name:   "condition cleared",
+			update: func(node *corev1.Node) { node.Status.Conditions = nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"drain": "true"}},
+				Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue},
+				}},
+			}
+			r, _ := newTestController(t, config, node)
+
+			drainCtx, done := r.startDrain(context.Background(), "node-1", tt.labelTriggered)
+			defer done()
+
+			if cancelled := drainCancelCause(drainCtx); cancelled != nil {
+				t.Fatalf("Expected drain to keep running before the update, got %v", cancelled)
+			}
+
+			current := getNode(t, r, "node-1")
+			tt.update(current)
+			if err := r.Update(context.Background(), current); err != nil {
+				t.Fatal(err)
+			}
+
+			cancelled := drainCancelCause(drainCtx)
+			switch {
+			case tt.expected == "" && cancelled != nil:
+				t.Errorf("Expected drain to keep running, got %v", cancelled)
+			case tt.expected != "" && cancelled == nil:
+				t.Errorf("Expected drain to be cancelled because %s", tt.expected)
+			case tt.expected != "" && cancelled.reason != tt.expected:
+				t.Errorf("Expected reason %q, got %q", tt.expected, cancelled.reason)
+			}
+		})
+	}
+}
+
+func TestCancelDrain(t *testing.T) {
+	r, _ := newTestController(t, &types.Config{}, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+
+	if r.CancelDrain("node-1", "requested through the API") {
+		t.Error("Expected no drain to cancel before one is started")
+	}
+
+	drainCtx, done := r.startDrain(context.Background(), "node-1", false)
+	if !r.isDrainActive("node-1") {
+		t.Error("Expected drain to be active")
+	}
+	if !r.CancelDrain("node-1", "requested through the API") {
+		t.Error("Expected the running drain to be cancelled")
+	}
+	if cancelled := drainCancelCause(drainCtx); cancelled == nil || cancelled.reason != "requested through the API" {
+		t.Errorf("Expected the drain context to carry the cancellation, got %v", context.Cause(drainCtx))
+	}
+
+	done()
+	if r.isDrainActive("node-1") {
+		t.Error("Expected drain to be inactive once done")
+	}
+}
+
+func TestHandleDrainCancelled(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: map[string]string{
+			"draino2.kubernetes.io/drain-in-progress": "true",
+			"draino2.kubernetes.io/drain-start-time":  time.Now().UTC().Format(time.RFC3339),
+			"draino2.kubernetes.io/drain-owner":       "test-instance",
+			"draino2.kubernetes.io/drain-cancel":      "true",
+			drainer.CordonedByAnnotation:              "true",
+		}},
+		Spec: corev1.NodeSpec{Unschedulable: true},
+	}
+	r, clientset := newTestController(t, &types.Config{DrainSettings: types.DrainSettings{UncordonOnCancel: true}}, node)
+
+	result, err := r.handleDrainCancelled(context.Background(), "node-1", &drainCancelledError{reason: "test"})
+	if err != nil || result.RequeueAfter != 0 {
+		t.Fatalf("Expected no error or requeue, got %v %+v", err, result)
+	}
+
+	current := getNode(t, r, "node-1")
+	for _, annotation := range []string{"drain-in-progress", "drain-start-time", "drain-owner", "drain-cancel"} {
+		if _, ok := current.Annotations["draino2.kubernetes.io/"+annotation]; ok {
+			t.Errorf("Expected %s annotation to be removed", annotation)
+		}
+	}
+	if !r.isDrainCancelled(current) {
+		t.Error("Expected node to be marked cancelled")
+	}
+
+	uncordoned, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncordoned.Spec.Unschedulable {
+		t.Error("Expected node cordoned by draino2 to be uncordoned")
+	}
+
+	var events []string
+	for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+		events = append(events, <-r.Recorder.(*record.FakeRecorder).Events)
+	}
+	if !strings.Contains(strings.Join(events, "\n"), "DrainCancelled") {
+		t.Errorf("Expected a DrainCancelled event, got %v", events)
+	}
+}
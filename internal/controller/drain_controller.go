@@ -2,8 +2,11 @@ package controller
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -13,10 +16,12 @@ import (
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/nfelsen/draino2/internal/drainer"
+	"github.com/nfelsen/draino2/internal/hooks"
 	"github.com/nfelsen/draino2/internal/metrics"
 	"github.com/nfelsen/draino2/internal/types"
 )
@@ -29,11 +34,34 @@ type DrainController struct {
 	Config   *types.Config
 	Drainer  *drainer.Drainer
 	Metrics  *metrics.Metrics
+	Hooks    *hooks.Runner
+	// InstanceID identifies this controller process in the drain-owner
+	// annotation, so drains interrupted by a restart can be told apart from
+	// drains another running instance is working on
+	InstanceID string
+
+	// triggers and exclusions are compiled from the configured label triggers
+	triggers   []labelMatcher
+	exclusions []labelMatcher
+
+	// reserved holds the nodes with a slot under the cluster-wide drain
+	// limits and queued the nodes held back by them
+	limitsLock sync.Mutex
+	reserved   map[string]bool
+	queued     map[string]bool
+
+	// drains holds the cancel function of each in-progress drain by node name
+	drainsLock sync.Mutex
+	drains     map[string]context.CancelCauseFunc
 }
 
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=replicationcontrollers;persistentvolumeclaims;persistentvolumes;namespaces,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=replicasets;statefulsets;daemonsets,verbs=get
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattachments,verbs=list
 
 // Reconcile handles the reconciliation of a Node
 func (r *DrainController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -46,23 +74,58 @@ func (r *DrainController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Node was deleted, nothing to do
+			r.dequeueDrain(req.Name)
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "Failed to get node")
 		return ctrl.Result{}, err
 	}
 
+	// A node uncordoned by someone else no longer carries draino2's cordon
+	if err := r.Drainer.ForgetCordon(ctx, node); err != nil {
+		log.Error(err, "Failed to forget cordon of uncordoned node", "node", node.Name)
+	}
+
 	// Check if node should be drained based on labels
-	shouldDrain, reason := r.shouldDrainNode(node)
+	shouldDrain, reason, wait := r.shouldDrainNode(node, time.Now())
 	if !shouldDrain {
 		log.V(2).Info("Node should not be drained", "node", node.Name, "reason", reason)
+		r.dequeueDrain(node.Name)
+		if r.isNodeBeingDrained(node) && !r.isDrainActive(node.Name) && !r.drainOwnedElsewhere(node, time.Now()) {
+			// The trigger went away while no one was draining the node
+			return ctrl.Result{}, r.clearInterruptedDrain(ctx, node, reason)
+		}
+		if r.isDrainCancelled(node) {
+			return ctrl.Result{}, r.clearDrainCancelled(ctx, node)
+		}
+		if r.isDrainFailed(node) {
+			// A new trigger starts over with a fresh retry budget
+			return ctrl.Result{}, r.clearDrainFailed(node, false)
+		}
+		// Check again once a pending condition has held long enough
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// A cancelled drain is not restarted until its trigger is removed
+	if r.isDrainCancelled(node) {
+		log.Info("Drain of node was cancelled, waiting for the trigger to be removed", "node", node.Name)
 		return ctrl.Result{}, nil
 	}
 
+	// In dry-run mode only compute what the drain would do
+	if r.Config.DryRun {
+		return ctrl.Result{}, r.planDrain(ctx, node, reason)
+	}
+
 	// Check if node is already being drained or has been drained
 	if r.isNodeBeingDrained(node) {
-		log.Info("Node is already being drained", "node", node.Name)
-		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		if r.isDrainActive(node.Name) {
+			log.Info("Node is already being drained", "node", node.Name)
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+		// No drain is running in this process, so it was interrupted or
+		// belongs to another instance
+		return r.recoverDrain(ctx, node, reason)
 	}
 
 	if r.isNodeDrained(node) {
@@ -70,18 +133,90 @@ func (r *DrainController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, nil
 	}
 
+	// A failed drain is only started again as allowed by the retry policy
+	if r.isDrainFailed(node) {
+		retry, wait := r.drainRetryDue(node, time.Now())
+		if !retry {
+			log.Info("Drain of node has failed", "node", node.Name,
+				"reason", node.Annotations["draino2.kubernetes.io/drain-failed"], "retryAfter", wait)
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		if err := r.clearDrainFailed(node, true); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to clear failed drain: %w", err)
+		}
+		log.Info("Retrying failed drain", "node", node.Name, "attempt", drainRetries(node))
+	}
+
+	// Respect the cluster-wide limits on draining and unschedulable nodes
+	ok, why, err := r.reserveDrainSlot(ctx, node)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !ok {
+		r.queueDrain(ctx, node, why)
+		return ctrl.Result{RequeueAfter: drainQueueInterval}, nil
+	}
+	defer r.releaseDrainSlot(node.Name)
+
+	// Make sure the evicted pods have somewhere to go before touching the node
+	if r.Config.DrainSettings.CapacityCheck.Enabled {
+		if err := r.Drainer.CheckCapacity(ctx, node); err != nil {
+			return r.handleCapacityCheckFailure(ctx, node, err)
+		}
+	}
+
+	// Every hook stage of this drain reports the pods it set out to evict
+	pods, err := r.drainPods(ctx, node)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Give external systems a chance to veto or delay the drain
+	if err := r.runHook(ctx, hooks.StagePreCordon, node, reason, pods, nil); err != nil {
+		return r.handleHookError(ctx, node, err)
+	}
+
 	// Start draining the node
 	log.Info("Starting drain operation", "node", node.Name, "reason", reason)
 
 	// Record audit event
 	r.recordDrainStart(node, reason)
 
-	// Perform the drain operation
-	err = r.performDrain(ctx, node, reason)
+	return r.runDrain(ctx, node, reason, pods, false)
+}
+
+// runDrain performs a new or resumed drain in a context that cancellation
+// requests can stop, and handles its outcome
+func (r *DrainController) runDrain(ctx context.Context, node *corev1.Node, reason string, pods []string, resume bool) (ctrl.Result, error) {
+	log := klog.FromContext(ctx)
+
+	_, labelTriggered := matchLabels(r.triggers, node)
+	drainCtx, done := r.startDrain(ctx, node.Name, labelTriggered)
+	err := r.performDrain(drainCtx, node, reason, pods, resume)
+	cause := context.Cause(drainCtx)
+	done()
 	if err != nil {
+		var (
+			cancelled *drainCancelledError
+			stuck     *drainStuckError
+		)
+		if stderrors.As(cause, &cancelled) {
+			return r.handleDrainCancelled(ctx, node.Name, cancelled)
+		}
+		if stderrors.As(cause, &stuck) {
+			return r.handleDrainStuck(ctx, node, reason, pods, stuck)
+		}
+
+		var (
+			vetoErr  *hooks.VetoError
+			delayErr *hooks.DelayError
+		)
+		if stderrors.As(err, &vetoErr) || stderrors.As(err, &delayErr) {
+			return r.handleHookError(ctx, node, err)
+		}
+
 		log.Error(err, "Failed to drain node", "node", node.Name)
-		r.recordDrainFailure(node, reason, err)
-		return ctrl.Result{RequeueAfter: 5 * time.Minute}, err
+		return r.failDrain(ctx, node, reason, pods, err)
 	}
 
 	log.Info("Successfully drained node", "node", node.Name)
@@ -90,29 +225,132 @@ func (r *DrainController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	return ctrl.Result{}, nil
 }
 
-// shouldDrainNode checks if a node should be drained based on labels and conditions
-func (r *DrainController) shouldDrainNode(node *corev1.Node) (bool, string) {
+// handleCapacityCheckFailure defers or refuses a drain whose pods would not
+// fit on the remaining nodes
+func (r *DrainController) handleCapacityCheckFailure(ctx context.Context, node *corev1.Node, err error) (ctrl.Result, error) {
+	log := klog.FromContext(ctx)
+
+	var capacityErr *drainer.InsufficientCapacityError
+	if !stderrors.As(err, &capacityErr) {
+		log.Error(err, "Failed to check capacity", "node", node.Name)
+		return ctrl.Result{}, err
+	}
+
+	deferInterval := r.Config.DrainSettings.CapacityCheck.DeferInterval
+	if deferInterval > 0 {
+		log.Info("Deferring drain, not enough capacity on remaining nodes", "node", node.Name, "retryAfter", deferInterval)
+		r.Recorder.Eventf(node, corev1.EventTypeWarning, "DrainDeferred",
+			"Drain deferred for %s: %v", deferInterval, capacityErr)
+		return ctrl.Result{RequeueAfter: deferInterval}, nil
+	}
+
+	log.Info("Refusing drain, not enough capacity on remaining nodes", "node", node.Name)
+	r.Recorder.Eventf(node, corev1.EventTypeWarning, "DrainRefused",
+		"Drain refused: %v", capacityErr)
+	return ctrl.Result{}, nil
+}
+
+// runHook calls the hooks registered for a stage with the node, the drain
+// reason and the pods listed by drainPods when the drain started
+func (r *DrainController) runHook(ctx context.Context, stage hooks.Stage, node *corev1.Node, reason string, pods []string, drainErr error) error {
+	if !r.Hooks.Handles(stage) {
+		return nil
+	}
+
+	payload := hooks.Payload{
+		Node:   node.Name,
+		Reason: reason,
+		Pods:   pods,
+	}
+	if drainErr != nil {
+		payload.Error = drainErr.Error()
+	}
+	return r.Hooks.Run(ctx, stage, payload)
+}
+
+// drainPods lists the pods a drain of the node is going to evict, for the hook
+// payloads. It is taken once per drain, before cordoning, so that every stage
+// reports the same pods rather than the ones left at the time.
+func (r *DrainController) drainPods(ctx context.Context, node *corev1.Node) ([]string, error) {
+	if !r.Hooks.Configured() {
+		return nil, nil
+	}
+	pods, err := r.Drainer.PodsToEvict(ctx, node.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for hooks: %w", err)
+	}
+	return pods, nil
+}
+
+// handleHookError stops a drain that a hook vetoed, delayed or could not be
+// called for, and decides when to reconsider the node
+func (r *DrainController) handleHookError(ctx context.Context, node *corev1.Node, err error) (ctrl.Result, error) {
+	log := klog.FromContext(ctx)
+
+	var (
+		vetoErr  *hooks.VetoError
+		delayErr *hooks.DelayError
+	)
+	switch {
+	case stderrors.As(err, &delayErr):
+		log.Info("Drain delayed by hook", "node", node.Name, "hook", delayErr.Hook, "retryAfter", delayErr.After)
+		r.Recorder.Eventf(node, corev1.EventTypeNormal, "DrainDelayed",
+			"Drain delayed for %s: %v", delayErr.After, delayErr)
+		return ctrl.Result{RequeueAfter: delayErr.After}, nil
+	case stderrors.As(err, &vetoErr):
+		log.Info("Drain vetoed by hook", "node", node.Name, "hook", vetoErr.Hook, "message", vetoErr.Message)
+		r.Recorder.Eventf(node, corev1.EventTypeWarning, "DrainVetoed",
+			"Drain vetoed: %v", vetoErr)
+		return ctrl.Result{}, nil
+	default:
+		log.Error(err, "Drain hook failed", "node", node.Name)
+		r.Recorder.Eventf(node, corev1.EventTypeWarning, "DrainHookFailed",
+			"Drain hook failed: %v", err)
+		return ctrl.Result{}, err
+	}
+}
+
+// shouldDrainNode checks if a node should be drained based on labels and
+// conditions. A condition only triggers a drain once it has had the
+// configured status for its MinimumDuration; until then the returned
+// duration is how long remains.
+func (r *DrainController) shouldDrainNode(node *corev1.Node, now time.Time) (bool, string, time.Duration) {
+	// Excluded nodes are never drained
+	if exclusion, excluded := matchLabels(r.exclusions, node); excluded {
+		return false, fmt.Sprintf("excluded by label %s", exclusion.describe(node)), 0
+	}
+
 	// Check drain trigger labels
-	for _, triggerLabel := range r.Config.LabelTriggers {
-		if value, exists := node.Labels[triggerLabel.Key]; exists {
-			if triggerLabel.Value == "" || value == triggerLabel.Value {
-				return true, fmt.Sprintf("trigger label %s=%s", triggerLabel.Key, value)
-			}
-		}
+	if trigger, matched := matchLabels(r.triggers, node); matched {
+		return true, fmt.Sprintf("trigger label %s", trigger.describe(node)), 0
 	}
 
 	// Check node conditions
-	for _, condition := range node.Status.Conditions {
-		if condition.Status == corev1.ConditionTrue {
-			for _, drainCondition := range r.Config.NodeConditions {
-				if condition.Type == drainCondition.Type {
-					return true, fmt.Sprintf("condition %s is True", condition.Type)
-				}
+	var wait time.Duration
+	for _, drainCondition := range r.Config.NodeConditions {
+		status := drainCondition.Status
+		if status == "" {
+			status = corev1.ConditionTrue
+		}
+
+		for _, condition := range node.Status.Conditions {
+			if condition.Type != drainCondition.Type || condition.Status != status {
+				continue
+			}
+			held := now.Sub(condition.LastTransitionTime.Time)
+			if held >= drainCondition.MinimumDuration {
+				return true, fmt.Sprintf("condition %s is %s", condition.Type, condition.Status), 0
+			}
+			if remaining := drainCondition.MinimumDuration - held; wait == 0 || remaining < wait {
+				wait = remaining
 			}
 		}
 	}
 
-	return false, "no drain triggers found"
+	if wait > 0 {
+		return false, fmt.Sprintf("condition has not held for its minimum duration, %s remaining", wait.Round(time.Second)), wait
+	}
+	return false, "no drain triggers found", 0
 }
 
 // isNodeBeingDrained checks if a node is currently being drained
@@ -133,12 +371,17 @@ func (r *DrainController) isNodeDrained(node *corev1.Node) bool {
 	return false
 }
 
-// performDrain performs the actual drain operation
-func (r *DrainController) performDrain(ctx context.Context, node *corev1.Node, reason string) error {
+// performDrain performs the actual drain operation. A resumed drain takes
+// over the existing drain-in-progress annotations instead of starting anew.
+func (r *DrainController) performDrain(ctx context.Context, node *corev1.Node, reason string, pods []string, resume bool) error {
 	log := klog.FromContext(ctx)
 
 	// Mark node as being drained
-	if err := r.markNodeAsDraining(node); err != nil {
+	if resume {
+		if err := r.takeOverDrain(node); err != nil {
+			return fmt.Errorf("failed to take over drain: %w", err)
+		}
+	} else if err := r.markNodeAsDraining(node); err != nil {
 		return fmt.Errorf("failed to mark node as draining: %w", err)
 	}
 
@@ -150,17 +393,63 @@ func (r *DrainController) performDrain(ctx context.Context, node *corev1.Node, r
 		}
 	}
 
+	// A veto or delay after cordoning rolls back the cordon and the
+	// drain-in-progress mark, so the next reconcile starts over
+	if err := r.runHook(ctx, hooks.StagePostCordon, node, reason, pods, nil); err != nil {
+		var (
+			vetoErr  *hooks.VetoError
+			delayErr *hooks.DelayError
+		)
+		if stderrors.As(err, &vetoErr) || stderrors.As(err, &delayErr) {
+			r.rollBackCordon(ctx, node)
+		}
+		return err
+	}
+
 	// Perform drain
 	log.Info("Draining node", "node", node.Name)
 	if err := r.Drainer.Drain(ctx, node); err != nil {
 		return fmt.Errorf("failed to drain node: %w", err)
 	}
 
+	// Make sure no volumes are left attached before the node is considered safe to remove
+	if r.Config.DrainSettings.WaitForVolumeDetach {
+		if err := r.Drainer.WaitForVolumeDetach(ctx, node); err != nil {
+			var attachedErr *drainer.VolumesAttachedError
+			if stderrors.As(err, &attachedErr) {
+				if annotateErr := r.annotateAttachedVolumes(node, attachedErr.Volumes); annotateErr != nil {
+					log.Error(annotateErr, "Failed to record attached volumes", "node", node.Name)
+				}
+			}
+			return fmt.Errorf("failed waiting for volumes to detach: %w", err)
+		}
+	}
+
 	// Mark node as drained
 	if err := r.markNodeAsDrained(node, reason); err != nil {
 		return fmt.Errorf("failed to mark node as drained: %w", err)
 	}
 
+	// The drain is complete, a failing hook does not change that
+	if err := r.runHook(ctx, hooks.StagePostDrain, node, reason, pods, nil); err != nil {
+		log.Error(err, "Post-drain hook did not succeed", "node", node.Name)
+	}
+
+	return nil
+}
+
+// planDrain computes and records a drain plan without changing the cluster
+func (r *DrainController) planDrain(ctx context.Context, node *corev1.Node, reason string) error {
+	log := klog.FromContext(ctx)
+	log.Info("Dry run: planning drain", "node", node.Name, "reason", reason)
+
+	plan, err := r.Drainer.Plan(ctx, node, !r.Config.DrainSettings.SkipCordon)
+	if err != nil {
+		log.Error(err, "Failed to plan drain", "node", node.Name)
+		return fmt.Errorf("failed to plan drain: %w", err)
+	}
+
+	r.Drainer.RecordPlan(ctx, node, plan)
 	return nil
 }
 
@@ -172,12 +461,47 @@ func (r *DrainController) markNodeAsDraining(node *corev1.Node) error {
 		node.Annotations = make(map[string]string)
 	}
 
+	now := time.Now().UTC().Format(time.RFC3339)
 	node.Annotations["draino2.kubernetes.io/drain-in-progress"] = "true"
-	node.Annotations["draino2.kubernetes.io/drain-start-time"] = time.Now().UTC().Format(time.RFC3339)
+	node.Annotations["draino2.kubernetes.io/drain-start-time"] = now
+	node.Annotations["draino2.kubernetes.io/drain-owner"] = r.InstanceID
+	node.Annotations["draino2.kubernetes.io/drain-heartbeat"] = now
+
+	return r.Patch(context.Background(), node, patch)
+}
+
+// unmarkNodeAsDraining removes the annotations added by markNodeAsDraining
+func (r *DrainController) unmarkNodeAsDraining(node *corev1.Node) error {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	delete(node.Annotations, "draino2.kubernetes.io/drain-in-progress")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-start-time")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-owner")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-heartbeat")
 
 	return r.Patch(context.Background(), node, patch)
 }
 
+// rollBackCordon undoes markNodeAsDraining and the cordon of a drain stopped
+// by a hook. A cordon draino2 does not own, such as a manual one, is kept.
+func (r *DrainController) rollBackCordon(ctx context.Context, node *corev1.Node) {
+	log := klog.FromContext(ctx)
+
+	// The patch response carries the cordon, which Uncordon needs to see
+	if err := r.unmarkNodeAsDraining(node); err != nil {
+		log.Error(err, "Failed to clear drain-in-progress annotation", "node", node.Name)
+	}
+	if r.Config.DrainSettings.SkipCordon {
+		return
+	}
+
+	err := r.Drainer.Uncordon(ctx, node, false)
+	var notOwnedErr *drainer.CordonNotOwnedError
+	if err != nil && !stderrors.As(err, &notOwnedErr) {
+		log.Error(err, "Failed to roll back cordon", "node", node.Name)
+	}
+}
+
 // markNodeAsDrained adds annotation to mark node as drained
 func (r *DrainController) markNodeAsDrained(node *corev1.Node, reason string) error {
 	patch := client.MergeFrom(node.DeepCopy())
@@ -187,6 +511,10 @@ func (r *DrainController) markNodeAsDrained(node *corev1.Node, reason string) er
 	}
 
 	delete(node.Annotations, "draino2.kubernetes.io/drain-in-progress")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-owner")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-heartbeat")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-retries")
+	delete(node.Annotations, "draino2.kubernetes.io/attached-volumes")
 	node.Annotations["draino2.kubernetes.io/drained"] = "true"
 	node.Annotations["draino2.kubernetes.io/drain-complete-time"] = time.Now().UTC().Format(time.RFC3339)
 	node.Annotations["draino2.kubernetes.io/drain-reason"] = reason
@@ -194,6 +522,19 @@ func (r *DrainController) markNodeAsDrained(node *corev1.Node, reason string) er
 	return r.Patch(context.Background(), node, patch)
 }
 
+// annotateAttachedVolumes records the volumes still attached to a node
+func (r *DrainController) annotateAttachedVolumes(node *corev1.Node, volumes []string) error {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+
+	node.Annotations["draino2.kubernetes.io/attached-volumes"] = strings.Join(volumes, ",")
+
+	return r.Patch(context.Background(), node, patch)
+}
+
 // recordDrainStart records the start of a drain operation
 func (r *DrainController) recordDrainStart(node *corev1.Node, reason string) {
 	r.Recorder.Eventf(node, corev1.EventTypeNormal, "DrainStarted",
@@ -226,6 +567,13 @@ func (r *DrainController) recordDrainFailure(node *corev1.Node, reason string, e
 
 // SetupWithManager sets up the controller with the given manager
 func (r *DrainController) SetupWithManager(mgr ctrl.Manager) error {
+	if err := r.setupLabelMatchers(); err != nil {
+		return err
+	}
+	if err := validateTopologyBudgets(r.Config.DrainSettings.TopologyBudgets); err != nil {
+		return err
+	}
+
 	// Create predicate to filter nodes
 	nodePredicate := predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
@@ -245,6 +593,11 @@ func (r *DrainController) SetupWithManager(mgr ctrl.Manager) error {
 				return r.shouldWatchNode(newNode)
 			}
 
+			// Forget draino2's cordon once someone else uncordons the node
+			if _, owned := newNode.Annotations[drainer.CordonedByAnnotation]; owned && !r.Drainer.IsCordoned(newNode) {
+				return r.Drainer.IsCordoned(oldNode)
+			}
+
 			return false
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
@@ -258,19 +611,13 @@ func (r *DrainController) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Node{}).
 		WithEventFilter(nodePredicate).
+		WithOptions(crcontroller.Options{MaxConcurrentReconciles: reconcileWorkers(r.Config.DrainSettings)}).
 		Complete(r)
 }
 
 // shouldWatchNode determines if a node should be watched based on filters
 func (r *DrainController) shouldWatchNode(node *corev1.Node) bool {
 	// Check skip labels
-	for _, skipLabel := range r.Config.ExcludeLabels {
-		if value, exists := node.Labels[skipLabel.Key]; exists {
-			if skipLabel.Value == "" || value == skipLabel.Value {
-				return false
-			}
-		}
-	}
-
-	return true
+	_, excluded := matchLabels(r.exclusions, node)
+	return !excluded
 }
@@ -0,0 +1,484 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/nfelsen/draino2/internal/drainer"
+	"github.com/nfelsen/draino2/internal/hooks"
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+// newTestController returns a controller whose cached client and drainer
+// clientset both start out with the given nodes
+func newTestController(t *testing.T, config *types.Config, nodes ...*corev1.Node) (*DrainController, *k8sfake.Clientset) {
+	t.Helper()
+
+	var (
+		objects        []client.Object
+		runtimeObjects []runtime.Object
+	)
+	for _, node := range nodes {
+		objects = append(objects, node.DeepCopy())
+		runtimeObjects = append(runtimeObjects, node.DeepCopy())
+	}
+	clientset := k8sfake.NewSimpleClientset(runtimeObjects...)
+	recorder := record.NewFakeRecorder(100)
+
+	r := &DrainController{
+		Client:     fake.NewClientBuilder().WithObjects(objects...).Build(),
+		Recorder:   recorder,
+		Config:     config,
+		Drainer:    drainer.NewDrainer(clientset, recorder, nil, &drainer.DrainerConfig{}),
+		InstanceID: "test-instance",
+	}
+	if err := r.setupLabelMatchers(); err != nil {
+		t.Fatalf("Expected valid label triggers, got %v", err)
+	}
+	return r, clientset
+}
+
+// getNode reads a node back from the controller's client
+func getNode(t *testing.T, r *DrainController, name string) *corev1.Node {
+	t.Helper()
+
+	node := &corev1.Node{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: name}, node); err != nil {
+		t.Fatalf("Failed to get node %s: %v", name, err)
+	}
+	return node
+}
+
+func TestPerformDrain_PostCordonVetoRollsBackCordon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		cordoned    bool
+	}{
+		{name: "cordoned by draino2", annotations: map[string]string{drainer.CordonedByAnnotation: "true"}, cordoned: false},
+		{name: "cordoned manually", cordoned: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: tt.annotations},
+				Spec:       corev1.NodeSpec{Unschedulable: true},
+			}
+			r, clientset := newTestController(t, &types.Config{}, node)
+			r.Hooks = hooks.NewRunner([]types.HookConfig{{Name: "gate", URL: server.URL, Stages: []string{"postCordon"}}})
+
+			err := r.performDrain(context.Background(), getNode(t, r, "node-1"), "test", nil, false)
+			var vetoErr *hooks.VetoError
+			if !stderrors.As(err, &vetoErr) {
+				t.Fatalf("Expected a veto, got %v", err)
+			}
+
+			if r.isNodeBeingDrained(getNode(t, r, "node-1")) {
+				t.Error("Expected the drain-in-progress annotation to be removed")
+			}
+			current, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.Spec.Unschedulable != tt.cordoned {
+				t.Errorf("Expected unschedulable=%t after the veto, got %t", tt.cordoned, current.Spec.Unschedulable)
+			}
+		})
+	}
+}
+
+func TestReconcile_HookPayloadPods(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		payloads []hooks.Payload
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload hooks.Payload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	config := &types.Config{
+		LabelTriggers: []types.LabelTrigger{{Key: "drain", Value: "true"}},
+		DrainSettings: types.DrainSettings{SkipCordon: true},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"drain": "true"}}}
+	r, clientset := newTestController(t, config, node)
+	r.Hooks = hooks.NewRunner([]types.HookConfig{{
+		Name:   "audit",
+		URL:    server.URL,
+		Stages: []string{"preCordon", "postCordon", "postDrain"},
+	}})
+	r.Drainer = drainer.NewDrainer(clientset, record.NewFakeRecorder(100), nil, &drainer.DrainerConfig{EvictUnreplicatedPods: true})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	}
+	if _, err := clientset.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// The fake clientset accepts evictions without deleting the pod
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		gvr := corev1.SchemeGroupVersion.WithResource("pods")
+		return true, eviction, clientset.Tracker().Delete(gvr, eviction.Namespace, eviction.Name)
+	})
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "node-1"}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !r.isNodeDrained(getNode(t, r, "node-1")) {
+		t.Fatal("Expected the node to be drained")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 3 {
+		t.Fatalf("Expected 3 hook calls, got %d", len(payloads))
+	}
+	for _, payload := range payloads {
+		if len(payload.Pods) != 1 || payload.Pods[0] != "default/web-0" {
+			t.Errorf("Expected stage %s to report default/web-0, got %v", payload.Stage, payload.Pods)
+		}
+	}
+}
+
+func TestReconcile_ForgetsCordonOfUncordonedNode(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node-1",
+		Annotations: map[string]string{drainer.CordonedByAnnotation: "true"},
+	}}
+	r, clientset := newTestController(t, &types.Config{}, node)
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "node-1"}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	current, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := current.Annotations[drainer.CordonedByAnnotation]; ok {
+		t.Errorf("Expected %s to be removed once the node was uncordoned", drainer.CordonedByAnnotation)
+	}
+}
+
+func TestShouldDrainNode_ConditionMinimumDuration(t *testing.T) {
+	now := time.Now()
+	r := &DrainController{Config: &types.Config{
+		NodeConditions: []types.NodeCondition{
+			{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue, MinimumDuration: 10 * time.Minute},
+		},
+	}}
+
+	node := &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{{
+		Type:               corev1.NodeMemoryPressure,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(now.Add(-4 * time.Minute)),
+	}}}}
+
+	shouldDrain, _, wait := r.shouldDrainNode(node, now)
+	if shouldDrain {
+		t.Error("Expected condition held for less than its minimum duration not to trigger a drain")
+	}
+	if wait != 6*time.Minute {
+		t.Errorf("Expected to wait 6m, got %s", wait)
+	}
+
+	node.Status.Conditions[0].LastTransitionTime = metav1.NewTime(now.Add(-11 * time.Minute))
+	if shouldDrain, _, _ := r.shouldDrainNode(node, now); !shouldDrain {
+		t.Error("Expected condition held past its minimum duration to trigger a drain")
+	}
+}
+
+func TestShouldDrainNode_ConditionStatus(t *testing.T) {
+	now := time.Now()
+	r := &DrainController{Config: &types.Config{
+		NodeConditions: []types.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionUnknown},
+			{Type: corev1.NodeDiskPressure},
+		},
+	}}
+
+	node := &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+		{Type: corev1.NodeDiskPressure, Status: corev1.ConditionFalse},
+	}}}
+	if shouldDrain, _, _ := r.shouldDrainNode(node, now); shouldDrain {
+		t.Error("Expected healthy node not to be drained")
+	}
+
+	node.Status.Conditions[0].Status = corev1.ConditionUnknown
+	if shouldDrain, reason, _ := r.shouldDrainNode(node, now); !shouldDrain {
+		t.Error("Expected Ready=Unknown to trigger a drain")
+	} else if reason != "condition Ready is Unknown" {
+		t.Errorf("Unexpected reason %q", reason)
+	}
+
+	node.Status.Conditions[0].Status = corev1.ConditionTrue
+	node.Status.Conditions[1].Status = corev1.ConditionTrue
+	if shouldDrain, _, _ := r.shouldDrainNode(node, now); !shouldDrain {
+		t.Error("Expected a condition without status to match True")
+	}
+}
+
+func TestShouldDrainNode_LabelTriggers(t *testing.T) {
+	r := &DrainController{Config: &types.Config{
+		LabelTriggers: []types.LabelTrigger{
+			{Key: "drain", Value: "true"},
+			{Key: "decommission"},
+			{Key: "maintenance-window", Operator: "In", Values: []string{"tonight", "now"}},
+			{Key: "ticket", Regex: "OPS-[0-9]+"},
+			{Key: "pool", Operator: "NotIn", Values: []string{"stable"}},
+		},
+		ExcludeLabels: []types.LabelTrigger{
+			{Key: "node-role.kubernetes.io/control-plane", Operator: "Exists"},
+		},
+	}}
+	if err := r.setupLabelMatchers(); err != nil {
+		t.Fatalf("Expected valid label triggers, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected bool
+	}{
+		{"no labels", nil, false},
+		{"exact value", map[string]string{"drain": "true"}, true},
+		{"other value", map[string]string{"drain": "false"}, false},
+		{"any value", map[string]string{"decommission": ""}, true},
+		{"in values", map[string]string{"maintenance-window": "now"}, true},
+		{"not in values", map[string]string{"maintenance-window": "tomorrow"}, false},
+		{"regex match", map[string]string{"ticket": "OPS-123"}, true},
+		{"regex partial match", map[string]string{"ticket": "OPS-123-old"}, false},
+		{"pool not in values", map[string]string{"pool": "canary"}, true},
+		{"pool in values", map[string]string{"pool": "stable"}, false},
+		{"excluded", map[string]string{"drain": "true", "node-role.kubernetes.io/control-plane": ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: tt.labels}}
+			if shouldDrain, reason, _ := r.shouldDrainNode(node, time.Now()); shouldDrain != tt.expected {
+				t.Errorf("Expected %t, got %t (%s)", tt.expected, shouldDrain, reason)
+			}
+		})
+	}
+}
+
+func TestNewLabelMatchers_Invalid(t *testing.T) {
+	invalid := []types.LabelTrigger{
+		{Key: "zone", Operator: "Gt", Values: []string{"1"}},
+		{Key: "zone", Operator: "In"},
+		{Key: "zone", Operator: "Exists", Values: []string{"a"}},
+		{Key: "zone", Regex: "("},
+		{Key: "zone", Operator: "DoesNotExist", Regex: "a"},
+	}
+	for _, trigger := range invalid {
+		if _, err := newLabelMatchers([]types.LabelTrigger{trigger}, false); err == nil {
+			t.Errorf("Expected %+v to be rejected", trigger)
+		}
+	}
+
+	doesNotExist := types.LabelTrigger{Key: "zone", Operator: "DoesNotExist"}
+	if _, err := newLabelMatchers([]types.LabelTrigger{doesNotExist}, true); err == nil {
+		t.Error("Expected DoesNotExist to be rejected as a drain trigger")
+	}
+}
+
+func TestNewLabelMatchers_Exclusions(t *testing.T) {
+	matchers, err := newLabelMatchers([]types.LabelTrigger{
+		{Key: "pool", Operator: "NotIn", Values: []string{"stable"}},
+		{Key: "managed", Operator: "DoesNotExist"},
+	}, false)
+	if err != nil {
+		t.Fatalf("Expected valid exclusions, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		matcher  int
+		labels   map[string]string
+		expected bool
+	}{
+		{"not in without the label", 0, map[string]string{"managed": "true"}, true},
+		{"not in values", 0, map[string]string{"pool": "canary"}, true},
+		{"in values", 0, map[string]string{"pool": "stable"}, false},
+		{"does not exist", 1, nil, true},
+		{"exists", 1, map[string]string{"managed": "true"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := matchers[tt.matcher].matches(tt.labels); result != tt.expected {
+				t.Errorf("Expected %t, got %t", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestEvaluateDrainLimits(t *testing.T) {
+	node := func(name string, unschedulable bool, annotations map[string]string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+			Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		}
+	}
+	draining := map[string]string{"draino2.kubernetes.io/drain-in-progress": "true"}
+	notCordoned := func(*corev1.Node) bool { return false }
+
+	tests := []struct {
+		name     string
+		settings types.DrainSettings
+		nodes    []corev1.Node
+		reserved map[string]bool
+		expected bool
+	}{
+		{
+			name:     "no limits",
+			nodes:    []corev1.Node{node("a", false, draining), node("b", false, nil)},
+			expected: true,
+		},
+		{
+			name:     "concurrent drain limit reached",
+			settings: types.DrainSettings{MaxConcurrentDrains: 1},
+			nodes:    []corev1.Node{node("a", false, draining), node("b", false, nil)},
+			expected: false,
+		},
+		{
+			name:     "reserved slot counts as draining",
+			settings: types.DrainSettings{MaxConcurrentDrains: 1},
+			nodes:    []corev1.Node{node("a", false, nil), node("b", false, nil)},
+			reserved: map[string]bool{"a": true},
+			expected: false,
+		},
+		{
+			name:     "concurrent drain limit not reached",
+			settings: types.DrainSettings{MaxConcurrentDrains: 2},
+			nodes:    []corev1.Node{node("a", false, draining), node("b", false, nil)},
+			expected: true,
+		},
+		{
+			name:     "manually cordoned nodes count as unschedulable",
+			settings: types.DrainSettings{MaxUnschedulablePercent: 25},
+			nodes: []corev1.Node{
+				node("a", true, nil), node("b", false, nil), node("c", false, nil), node("d", false, nil),
+			},
+			expected: false,
+		},
+		{
+			name:     "one node may drain when the percentage rounds down to zero",
+			settings: types.DrainSettings{MaxUnschedulablePercent: 20},
+			nodes: []corev1.Node{
+				node("a", false, nil), node("b", false, nil), node("c", false, nil), node("d", false, nil),
+			},
+			expected: true,
+		},
+		{
+			name:     "second node may not drain when the percentage rounds down to zero",
+			settings: types.DrainSettings{MaxUnschedulablePercent: 20},
+			nodes: []corev1.Node{
+				node("a", false, draining), node("b", false, nil), node("c", false, nil), node("d", false, nil),
+			},
+			expected: false,
+		},
+		{
+			name:     "unschedulable percent within limit",
+			settings: types.DrainSettings{MaxUnschedulablePercent: 50},
+			nodes: []corev1.Node{
+				node("a", true, nil), node("b", false, nil), node("c", false, nil), node("d", false, nil),
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, why := evaluateDrainLimits(tt.settings, tt.nodes, &tt.nodes[1], tt.reserved, notCordoned)
+			if ok != tt.expected {
+				t.Errorf("Expected %t, got %t (%s)", tt.expected, ok, why)
+			}
+		})
+	}
+}
+
+func TestEvaluateDrainLimits_TopologyBudgets(t *testing.T) {
+	node := func(name, zone, pool string, draining bool) corev1.Node {
+		n := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{
+			"topology.kubernetes.io/zone": zone,
+			"node-pool":                   pool,
+		}}}
+		if draining {
+			n.Annotations = map[string]string{"draino2.kubernetes.io/drain-in-progress": "true"}
+		}
+		return n
+	}
+	settings := types.DrainSettings{TopologyBudgets: []types.TopologyBudget{
+		{LabelKey: "topology.kubernetes.io/zone", MaxDraining: 1},
+		{LabelKey: "node-pool", MaxDraining: 2},
+	}}
+	notCordoned := func(*corev1.Node) bool { return false }
+
+	nodes := []corev1.Node{
+		node("a", "zone-a", "pool-1", true),
+		node("b", "zone-b", "pool-1", true),
+		node("c", "zone-a", "pool-2", false),
+		node("d", "zone-c", "pool-1", false),
+		node("e", "zone-c", "pool-2", false),
+	}
+
+	tests := []struct {
+		node     int
+		reserved map[string]bool
+		expected bool
+	}{
+		{node: 2, expected: false},                                       // zone-a already has a drain
+		{node: 3, expected: false},                                       // pool-1 already has two drains
+		{node: 4, expected: true},                                        // zone-c and pool-2 are free
+		{node: 4, reserved: map[string]bool{"d": true}, expected: false}, // reserved slot in zone-c
+	}
+
+	for _, tt := range tests {
+		ok, why := evaluateDrainLimits(settings, nodes, &nodes[tt.node], tt.reserved, notCordoned)
+		if ok != tt.expected {
+			t.Errorf("Expected node %s allowed %t, got %t (%s)", nodes[tt.node].Name, tt.expected, ok, why)
+		}
+	}
+
+	if err := validateTopologyBudgets([]types.TopologyBudget{{LabelKey: "zone"}}); err == nil {
+		t.Error("Expected budget without maxDraining to be rejected")
+	}
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+// labelMatcher matches node labels against one configured LabelTrigger. It
+// is used both for drain triggers and for exclusions.
+type labelMatcher struct {
+	key         string
+	requirement labels.Requirement
+	// regex, if set, must also match the whole label value
+	regex   *regexp.Regexp
+	pattern string
+	// requireKey makes a NotIn trigger match only nodes that have the label
+	requireKey bool
+}
+
+// newLabelMatchers compiles label triggers into matchers. A trigger without
+// an operator keeps the original behavior: any value if Value is empty,
+// otherwise exactly Value. Drain triggers only match nodes carrying the
+// label, so NotIn requires the label to be present and DoesNotExist is
+// rejected; exclusions use the label selector semantics as they are.
+func newLabelMatchers(triggers []types.LabelTrigger, drainTrigger bool) ([]labelMatcher, error) {
+	matchers := make([]labelMatcher, 0, len(triggers))
+	for _, trigger := range triggers {
+		matcher, err := newLabelMatcher(trigger, drainTrigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label trigger for key %q: %w", trigger.Key, err)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// newLabelMatcher compiles a single label trigger
+func newLabelMatcher(trigger types.LabelTrigger, drainTrigger bool) (labelMatcher, error) {
+	var op selection.Operator
+	values := trigger.Values
+	switch metav1.LabelSelectorOperator(trigger.Operator) {
+	case "":
+		if trigger.Value == "" {
+			op = selection.Exists
+		} else {
+			op, values = selection.In, []string{trigger.Value}
+		}
+	case metav1.LabelSelectorOpIn:
+		op = selection.In
+	case metav1.LabelSelectorOpNotIn:
+		op = selection.NotIn
+	case metav1.LabelSelectorOpExists:
+		op = selection.Exists
+	case metav1.LabelSelectorOpDoesNotExist:
+		if drainTrigger {
+			return labelMatcher{}, fmt.Errorf("operator %s cannot trigger a drain", trigger.Operator)
+		}
+		op = selection.DoesNotExist
+	default:
+		return labelMatcher{}, fmt.Errorf("unsupported operator %q", trigger.Operator)
+	}
+
+	requirement, err := labels.NewRequirement(trigger.Key, op, values)
+	if err != nil {
+		return labelMatcher{}, err
+	}
+
+	matcher := labelMatcher{
+		key:         trigger.Key,
+		requirement: *requirement,
+		requireKey:  drainTrigger && op == selection.NotIn,
+	}
+	if trigger.Regex != "" {
+		if op == selection.DoesNotExist {
+			return labelMatcher{}, fmt.Errorf("regex cannot be combined with operator %s", trigger.Operator)
+		}
+		matcher.pattern = trigger.Regex
+		matcher.regex, err = regexp.Compile("^(?:" + trigger.Regex + ")$")
+		if err != nil {
+			return labelMatcher{}, fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	return matcher, nil
+}
+
+// matches reports whether the labels satisfy the matcher
+func (m *labelMatcher) matches(nodeLabels map[string]string) bool {
+	if !m.requirement.Matches(labels.Set(nodeLabels)) {
+		return false
+	}
+	if _, exists := nodeLabels[m.key]; m.requireKey && !exists {
+		return false
+	}
+	if m.regex == nil {
+		return true
+	}
+	value, exists := nodeLabels[m.key]
+	return exists && m.regex.MatchString(value)
+}
+
+// String describes the matcher for logs and events
+func (m *labelMatcher) String() string {
+	if m.regex == nil {
+		return m.requirement.String()
+	}
+	return fmt.Sprintf("%s,%s=~/%s/", m.requirement.String(), m.key, m.pattern)
+}
+
+// describe explains a match against a node, naming the label value when the label is present
+func (m *labelMatcher) describe(node *corev1.Node) string {
+	if value, exists := node.Labels[m.key]; exists {
+		return m.key + "=" + value
+	}
+	return m.String()
+}
+
+// matchLabels returns the first matcher satisfied by the node's labels
+func matchLabels(matchers []labelMatcher, node *corev1.Node) (*labelMatcher, bool) {
+	for i := range matchers {
+		if matchers[i].matches(node.Labels) {
+			return &matchers[i], true
+		}
+	}
+	return nil, false
+}
+
+// setupLabelMatchers compiles the configured label triggers and exclusions
+func (r *DrainController) setupLabelMatchers() error {
+	var err error
+	if r.triggers, err = newLabelMatchers(r.Config.LabelTriggers, true); err != nil {
+		return err
+	}
+	if r.exclusions, err = newLabelMatchers(r.Config.ExcludeLabels, false); err != nil {
+		return fmt.Errorf("invalid exclude label: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+// drainQueueInterval is how often a node held back by the drain limits is reconsidered
+var drainQueueInterval = 1 * time.Minute
+
+// defaultReconcileWorkers is the number of reconcile workers when none is configured
+const defaultReconcileWorkers = 10
+
+// reconcileWorkers returns how many nodes are reconciled at the same time.
+// Drains hold their worker until they finish, so one worker more than
+// MaxConcurrentDrains is kept to queue, cancel and clean up other nodes
+// while drains run.
+func reconcileWorkers(settings types.DrainSettings) int {
+	workers := settings.ReconcileWorkers
+	if workers <= 0 {
+		workers = defaultReconcileWorkers
+	}
+	if settings.MaxConcurrentDrains > 0 && workers <= settings.MaxConcurrentDrains {
+		workers = settings.MaxConcurrentDrains + 1
+	}
+	return workers
+}
+
+// reserveDrainSlot checks the cluster-wide drain limits and, if the node may
+// be drained, reserves a slot for it until releaseDrainSlot is called.
+// Reservations are made under a lock so that concurrent reconciles cannot
+// both take the last slot before either drain is visible on the nodes.
+func (r *DrainController) reserveDrainSlot(ctx context.Context, node *corev1.Node) (bool, string, error) {
+	settings := r.Config.DrainSettings
+	if settings.MaxConcurrentDrains <= 0 && settings.MaxUnschedulablePercent <= 0 && len(settings.TopologyBudgets) == 0 {
+		return true, "", nil
+	}
+
+	r.limitsLock.Lock()
+	defer r.limitsLock.Unlock()
+
+	if r.reserved[node.Name] {
+		return true, "", nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return false, "", fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	if ok, why := evaluateDrainLimits(settings, nodes.Items, node, r.reserved, r.Drainer.IsCordoned); !ok {
+		return false, why, nil
+	}
+
+	if r.reserved == nil {
+		r.reserved = make(map[string]bool)
+	}
+	r.reserved[node.Name] = true
+	delete(r.queued, node.Name)
+	return true, "", nil
+}
+
+// releaseDrainSlot releases a slot taken by reserveDrainSlot
+func (r *DrainController) releaseDrainSlot(nodeName string) {
+	r.limitsLock.Lock()
+	defer r.limitsLock.Unlock()
+	delete(r.reserved, nodeName)
+}
+
+// evaluateDrainLimits decides whether draining the node would stay within
+// the limits. A node counts as draining if it has the drain-in-progress
+// annotation or a reserved slot, and as unschedulable if it is draining or
+// cordoned by anyone.
+func evaluateDrainLimits(settings types.DrainSettings, nodes []corev1.Node, node *corev1.Node, reserved map[string]bool, isCordoned func(*corev1.Node) bool) (bool, string) {
+	var draining, unschedulable int
+	drainingInGroup := make([]int, len(settings.TopologyBudgets))
+	for i := range nodes {
+		n := &nodes[i]
+		if n.Name == node.Name {
+			continue
+		}
+		_, inProgress := n.Annotations["draino2.kubernetes.io/drain-in-progress"]
+		isDraining := inProgress || reserved[n.Name]
+		if isDraining {
+			draining++
+			for j, budget := range settings.TopologyBudgets {
+				if sameGroup(n, node, budget.LabelKey) {
+					drainingInGroup[j]++
+				}
+			}
+		}
+		if isDraining || n.Spec.Unschedulable || isCordoned(n) {
+			unschedulable++
+		}
+	}
+
+	if settings.MaxConcurrentDrains > 0 && draining >= settings.MaxConcurrentDrains {
+		return false, fmt.Sprintf("%d node(s) already draining, limit is %d", draining, settings.MaxConcurrentDrains)
+	}
+
+	// The node being drained is about to become unschedulable as well. At
+	// least one node may always be unschedulable, so that small clusters
+	// where the percentage rounds down to zero nodes can still be drained.
+	if settings.MaxUnschedulablePercent > 0 && len(nodes) > 0 {
+		allowed := max(settings.MaxUnschedulablePercent*len(nodes)/100, 1)
+		if unschedulable+1 > allowed {
+			return false, fmt.Sprintf("%d of %d node(s) already unschedulable, draining another would exceed %d%%",
+				unschedulable, len(nodes), settings.MaxUnschedulablePercent)
+		}
+	}
+
+	for j, budget := range settings.TopologyBudgets {
+		if drainingInGroup[j] >= budget.MaxDraining {
+			if _, ok := node.Labels[budget.LabelKey]; ok {
+				return false, fmt.Sprintf("%d node(s) with %s=%s already draining, limit is %d",
+					drainingInGroup[j], budget.LabelKey, node.Labels[budget.LabelKey], budget.MaxDraining)
+			}
+		}
+	}
+
+	return true, ""
+}
+
+// validateTopologyBudgets rejects budgets without a label key or limit
+func validateTopologyBudgets(budgets []types.TopologyBudget) error {
+	for _, budget := range budgets {
+		if budget.LabelKey == "" {
+			return fmt.Errorf("topology budget requires a labelKey")
+		}
+		if budget.MaxDraining <= 0 {
+			return fmt.Errorf("topology budget for %s requires a positive maxDraining", budget.LabelKey)
+		}
+	}
+	return nil
+}
+
+// sameGroup reports whether both nodes have the same value for the label key
+func sameGroup(a, b *corev1.Node, key string) bool {
+	av, ok := a.Labels[key]
+	if !ok {
+		return false
+	}
+	bv, ok := b.Labels[key]
+	return ok && av == bv
+}
+
+// queueDrain holds back a drain that would exceed the drain limits. The
+// DrainQueued event is only emitted when the node is first queued, not on
+// every recheck.
+func (r *DrainController) queueDrain(ctx context.Context, node *corev1.Node, why string) {
+	log := klog.FromContext(ctx)
+
+	r.limitsLock.Lock()
+	alreadyQueued := r.queued[node.Name]
+	if r.queued == nil {
+		r.queued = make(map[string]bool)
+	}
+	r.queued[node.Name] = true
+	r.limitsLock.Unlock()
+
+	if alreadyQueued {
+		log.V(2).Info("Drain still queued by drain limits", "node", node.Name, "reason", why, "retryAfter", drainQueueInterval)
+		return
+	}
+
+	log.Info("Drain queued by drain limits", "node", node.Name, "reason", why, "retryAfter", drainQueueInterval)
+	r.Recorder.Eventf(node, corev1.EventTypeNormal, "DrainQueued",
+		"Drain of node %s queued: %s", node.Name, why)
+}
+
+// dequeueDrain forgets a queued drain whose node no longer needs draining
+func (r *DrainController) dequeueDrain(nodeName string) {
+	r.limitsLock.Lock()
+	defer r.limitsLock.Unlock()
+	delete(r.queued, nodeName)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+func TestReconcileWorkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings types.DrainSettings
+		expected int
+	}{
+		{"default", types.DrainSettings{}, defaultReconcileWorkers},
+		{"no drain limit", types.DrainSettings{ReconcileWorkers: 4}, 4},
+		{"above drain limit", types.DrainSettings{ReconcileWorkers: 4, MaxConcurrentDrains: 2}, 4},
+		{"at drain limit", types.DrainSettings{ReconcileWorkers: 2, MaxConcurrentDrains: 2}, 3},
+		{"default below drain limit", types.DrainSettings{MaxConcurrentDrains: 20}, 21},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if workers := reconcileWorkers(tt.settings); workers != tt.expected {
+				t.Errorf("Expected %d workers, got %d", tt.expected, workers)
+			}
+		})
+	}
+}
+
+func TestQueueDrain_EventOnce(t *testing.T) {
+	r, _ := newTestController(t, &types.Config{})
+	recorder := r.Recorder.(*record.FakeRecorder)
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+	queuedEvents := func() int {
+		count := 0
+		for {
+			select {
+			case event := <-recorder.Events:
+				if strings.Contains(event, "DrainQueued") {
+					count++
+				}
+			default:
+				return count
+			}
+		}
+	}
+
+	r.queueDrain(context.Background(), node, "limit reached")
+	r.queueDrain(context.Background(), node, "limit reached")
+	if count := queuedEvents(); count != 1 {
+		t.Errorf("Expected 1 DrainQueued event while queued, got %d", count)
+	}
+
+	r.dequeueDrain(node.Name)
+	r.queueDrain(context.Background(), node, "limit reached")
+	if count := queuedEvents(); count != 1 {
+		t.Errorf("Expected a new DrainQueued event after being dequeued, got %d", count)
+	}
+}
+
+func TestReserveDrainSlot_Concurrent(t *testing.T) {
+	zones := map[string]int{"zone-a": 4, "zone-b": 3}
+	var nodes []*corev1.Node
+	for zone, count := range zones {
+		for i := 0; i < count; i++ {
+			nodes = append(nodes, &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name:   fmt.Sprintf("%s-%d", zone, i),
+				Labels: map[string]string{"topology.kubernetes.io/zone": zone},
+			}})
+		}
+	}
+	config := &types.Config{DrainSettings: types.DrainSettings{
+		TopologyBudgets: []types.TopologyBudget{{LabelKey: "topology.kubernetes.io/zone", MaxDraining: 1}},
+	}}
+	r, _ := newTestController(t, config, nodes...)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved = make(map[string]int)
+		start    = make(chan struct{})
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *corev1.Node) {
+			defer wg.Done()
+			<-start
+			ok, _, err := r.reserveDrainSlot(context.Background(), node)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				reserved[node.Labels["topology.kubernetes.io/zone"]]++
+				mu.Unlock()
+			}
+		}(node)
+	}
+	close(start)
+	wg.Wait()
+
+	for zone := range zones {
+		if reserved[zone] != 1 {
+			t.Errorf("Expected 1 reserved slot in %s, got %d", zone, reserved[zone])
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/nfelsen/draino2/internal/hooks"
+)
+
+// drainHeartbeatInterval is how often the instance running a drain refreshes
+// the node's drain-heartbeat annotation
+var drainHeartbeatInterval = 1 * time.Minute
+
+// drainHeartbeatMisses is how many heartbeats another instance may miss before
+// its drain is considered interrupted
+const drainHeartbeatMisses = 3
+
+// recoverDrain handles a node marked drain-in-progress that no drain in this
+// process is working on. A drain owned by another instance that still
+// refreshes its heartbeat is left alone. Otherwise the drain was interrupted,
+// typically because the controller restarted: recent drains are resumed from
+// the eviction phase; drains older than the configured maximum age, or of
+// unknown age, are marked failed.
+func (r *DrainController) recoverDrain(ctx context.Context, node *corev1.Node, reason string) (ctrl.Result, error) {
+	log := klog.FromContext(ctx)
+	recovery := r.Config.DrainSettings.DrainRecovery
+	owner := node.Annotations["draino2.kubernetes.io/drain-owner"]
+
+	if r.drainOwnedElsewhere(node, time.Now()) {
+		log.Info("Node is being drained by another instance", "node", node.Name, "owner", owner)
+		return ctrl.Result{RequeueAfter: drainHeartbeatInterval}, nil
+	}
+
+	pods, err := r.drainPods(ctx, node)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if stuck := r.checkDrainStuck(node, time.Now()); stuck != nil {
+		return r.handleDrainStuck(ctx, node, reason, pods, stuck)
+	}
+
+	age, known := drainAge(node, time.Now())
+	switch {
+	case !known:
+		return r.failDrain(ctx, node, reason, pods, fmt.Errorf("interrupted drain has no valid drain-start-time"))
+	case !recovery.Resume:
+		return r.failDrain(ctx, node, reason, pods, fmt.Errorf("drain started %s ago was interrupted", age.Round(time.Second)))
+	case recovery.MaxAge > 0 && age > recovery.MaxAge:
+		return r.failDrain(ctx, node, reason, pods, fmt.Errorf("interrupted drain started %s ago exceeds the resume limit of %s",
+			age.Round(time.Second), recovery.MaxAge))
+	}
+
+	log.Info("Resuming interrupted drain", "node", node.Name, "previousOwner", owner, "age", age)
+	r.Recorder.Eventf(node, corev1.EventTypeNormal, "DrainResumed",
+		"Resuming drain of node %s started %s ago by %s", node.Name, age.Round(time.Second), ownerName(owner))
+	return r.runDrain(ctx, node, reason, pods, true)
+}
+
+// takeOverDrain records this process as the owner of a resumed drain,
+// keeping the original drain-start-time
+func (r *DrainController) takeOverDrain(node *corev1.Node) error {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+
+	node.Annotations["draino2.kubernetes.io/drain-owner"] = r.InstanceID
+	node.Annotations["draino2.kubernetes.io/drain-heartbeat"] = time.Now().UTC().Format(time.RFC3339)
+
+	return r.Patch(context.Background(), node, patch)
+}
+
+// drainOwnedElsewhere reports whether the node's drain belongs to another
+// instance whose heartbeat is recent enough for it to still be running
+func (r *DrainController) drainOwnedElsewhere(node *corev1.Node, now time.Time) bool {
+	owner := node.Annotations["draino2.kubernetes.io/drain-owner"]
+	if owner == "" || owner == r.InstanceID {
+		return false
+	}
+	heartbeat, err := time.Parse(time.RFC3339, node.Annotations["draino2.kubernetes.io/drain-heartbeat"])
+	if err != nil {
+		return false
+	}
+	return now.Sub(heartbeat) < drainHeartbeatMisses*drainHeartbeatInterval
+}
+
+// refreshHeartbeat updates the drain-heartbeat annotation of a drain this
+// process is running once it is older than the heartbeat interval
+func (r *DrainController) refreshHeartbeat(ctx context.Context, node *corev1.Node, now time.Time) error {
+	heartbeat, err := time.Parse(time.RFC3339, node.Annotations["draino2.kubernetes.io/drain-heartbeat"])
+	if err == nil && now.Sub(heartbeat) < drainHeartbeatInterval {
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	node.Annotations["draino2.kubernetes.io/drain-heartbeat"] = now.UTC().Format(time.RFC3339)
+
+	return r.Patch(ctx, node, patch)
+}
+
+// clearInterruptedDrain removes the drain-in-progress annotations of an
+// interrupted drain whose trigger is gone, so that it no longer counts as
+// draining. With UncordonOnCancel the node is uncordoned if draino2 cordoned it.
+func (r *DrainController) clearInterruptedDrain(ctx context.Context, node *corev1.Node, reason string) error {
+	log := klog.FromContext(ctx)
+	log.Info("Clearing interrupted drain", "node", node.Name, "reason", reason)
+
+	if err := r.unmarkNodeAsDraining(node); err != nil {
+		return fmt.Errorf("failed to clear interrupted drain: %w", err)
+	}
+
+	if r.Config.DrainSettings.UncordonOnCancel {
+		if err := r.Drainer.Uncordon(ctx, node, false); err != nil {
+			log.Error(err, "Failed to uncordon node after clearing interrupted drain", "node", node.Name)
+		}
+	}
+
+	r.Recorder.Eventf(node, corev1.EventTypeNormal, "InterruptedDrainCleared",
+		"Cleared interrupted drain of node %s: %s", node.Name, reason)
+	return nil
+}
+
+// failDrain records a failed drain, runs the failure hooks and puts the node
+// in the Failed state, to be retried as the retry policy allows
+func (r *DrainController) failDrain(ctx context.Context, node *corev1.Node, reason string, pods []string, err error) (ctrl.Result, error) {
+	log := klog.FromContext(ctx)
+
+	r.recordDrainFailure(node, reason, err)
+	if hookErr := r.runHook(ctx, hooks.StageFailure, node, reason, pods, err); hookErr != nil {
+		log.Error(hookErr, "Failure hook did not succeed", "node", node.Name)
+	}
+
+	if markErr := r.markNodeAsFailed(node, err.Error()); markErr != nil {
+		log.Error(markErr, "Failed to mark drain as failed", "node", node.Name)
+		return ctrl.Result{}, markErr
+	}
+
+	retry, wait := r.drainRetryDue(node, time.Now())
+	if !retry && wait == 0 {
+		log.Info("Drain failed and no retries are left", "node", node.Name, "retries", drainRetries(node))
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: wait}, nil
+}
+
+// markNodeAsFailed replaces the drain-in-progress annotations with
+// drain-failed and the reason
+func (r *DrainController) markNodeAsFailed(node *corev1.Node, failure string) error {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+
+	delete(node.Annotations, "draino2.kubernetes.io/drain-in-progress")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-start-time")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-owner")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-heartbeat")
+	node.Annotations["draino2.kubernetes.io/drain-failed"] = failure
+	node.Annotations["draino2.kubernetes.io/drain-failed-time"] = time.Now().UTC().Format(time.RFC3339)
+
+	return r.Patch(context.Background(), node, patch)
+}
+
+// isDrainFailed checks if a drain of the node has been marked failed
+func (r *DrainController) isDrainFailed(node *corev1.Node) bool {
+	_, exists := node.Annotations["draino2.kubernetes.io/drain-failed"]
+	return exists
+}
+
+// drainAge returns how long ago the node's drain started, and false if the
+// drain-start-time annotation is missing or invalid
+func drainAge(node *corev1.Node, now time.Time) (time.Duration, bool) {
+	started, err := time.Parse(time.RFC3339, node.Annotations["draino2.kubernetes.io/drain-start-time"])
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(started), true
+}
+
+// ownerName describes the instance that owned a drain for events
+func ownerName(owner string) string {
+	if owner == "" {
+		return "an unknown instance"
+	}
+	return "instance " + owner
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+// newDrainingNode returns a node marked drain-in-progress by owner, with the
+// given drain start and heartbeat times
+func newDrainingNode(owner string, started, heartbeat time.Time, labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: labels,
+			Annotations: map[string]string{
+				"draino2.kubernetes.io/drain-in-progress": "true",
+				"draino2.kubernetes.io/drain-start-time":  started.UTC().Format(time.RFC3339),
+				"draino2.kubernetes.io/drain-owner":       owner,
+				"draino2.kubernetes.io/drain-heartbeat":   heartbeat.UTC().Format(time.RFC3339),
+			},
+		},
+	}
+}
+
+func TestDrainOwnedElsewhere(t *testing.T) {
+	now := time.Now()
+	r := &DrainController{InstanceID: "test-instance"}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			name:        "no owner",
+			annotations: map[string]string{},
+			expected:    false,
+		},
+		{
+			name: "owned by this instance",
+			annotations: map[string]string{
+				"draino2.kubernetes.io/drain-owner":     "test-instance",
+				"draino2.kubernetes.io/drain-heartbeat": now.Format(time.RFC3339),
+			},
+			expected: false,
+		},
+		{
+			name: "other instance with recent heartbeat",
+			annotations: map[string]string{
+				"draino2.kubernetes.io/drain-owner":     "other-instance",
+				"draino2.kubernetes.io/drain-heartbeat": now.Add(-time.Minute).Format(time.RFC3339),
+			},
+			expected: true,
+		},
+		{
+			name: "other instance with stale heartbeat",
+			annotations: map[string]string{
+				"draino2.kubernetes.io/drain-owner":     "other-instance",
+				"draino2.kubernetes.io/drain-heartbeat": now.Add(-10 * time.Minute).Format(time.RFC3339),
+			},
+			expected: false,
+		},
+		{
+			name: "other instance without heartbeat",
+			annotations: map[string]string{
+				"draino2.kubernetes.io/drain-owner": "other-instance",
+			},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: tt.annotations}}
+			if result := r.drainOwnedElsewhere(node, now); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRecoverDrain(t *testing.T) {
+	now := time.Now()
+	labels := map[string]string{"drain": "true"}
+
+	tests := []struct {
+		name       string
+		node       *corev1.Node
+		resume     bool
+		result     ctrl.Result
+		inProgress bool
+		drained    bool
+		failed     bool
+		owner      string
+	}{
+		{
+			name:       "owned by a live instance",
+			node:       newDrainingNode("other-instance", now.Add(-5*time.Minute), now.Add(-30*time.Second), labels),
+			resume:     true,
+			result:     ctrl.Result{RequeueAfter: drainHeartbeatInterval},
+			inProgress: true,
+			owner:      "other-instance",
+		},
+		{
+			name:    "interrupted and resumed",
+			node:    newDrainingNode("other-instance", now.Add(-5*time.Minute), now.Add(-5*time.Minute), labels),
+			resume:  true,
+			drained: true,
+		},
+		{
+			name:   "interrupted with resume disabled",
+			node:   newDrainingNode("other-instance", now.Add(-5*time.Minute), now.Add(-5*time.Minute), labels),
+			failed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.Config{
+				LabelTriggers: []types.LabelTrigger{{Key: "drain", Value: "true"}},
+				DrainSettings: types.DrainSettings{
+					SkipCordon:    true,
+					DrainRecovery: types.DrainRecovery{Resume: tt.resume},
+				},
+			}
+			r, _ := newTestController(t, config, tt.node)
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "node-1"}})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tt.result.RequeueAfter != 0 && result != tt.result {
+				t.Errorf("Expected result %+v, got %+v", tt.result, result)
+			}
+
+			node := getNode(t, r, "node-1")
+			if inProgress := r.isNodeBeingDrained(node); inProgress != tt.inProgress {
+				t.Errorf("Expected drain-in-progress %v, got %v", tt.inProgress, inProgress)
+			}
+			if drained := r.isNodeDrained(node); drained != tt.drained {
+				t.Errorf("Expected drained %v, got %v", tt.drained, drained)
+			}
+			if failed := r.isDrainFailed(node); failed != tt.failed {
+				t.Errorf("Expected drain-failed %v, got %v", tt.failed, failed)
+			}
+			if owner := node.Annotations["draino2.kubernetes.io/drain-owner"]; owner != tt.owner {
+				t.Errorf("Expected drain-owner %q, got %q", tt.owner, owner)
+			}
+		})
+	}
+}
+
+func TestReconcile_ClearsInterruptedDrain(t *testing.T) {
+	now := time.Now()
+	config := &types.Config{
+		LabelTriggers: []types.LabelTrigger{{Key: "drain", Value: "true"}},
+	}
+
+	tests := []struct {
+		name    string
+		owner   string
+		cleared bool
+	}{
+		{name: "interrupted drain", owner: "other-instance", cleared: true},
+		{name: "owned by a live instance", owner: "live-instance", cleared: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heartbeat := now.Add(-10 * time.Minute)
+			if !tt.cleared {
+				heartbeat = now
+			}
+			r, _ := newTestController(t, config, newDrainingNode(tt.owner, now.Add(-time.Hour), heartbeat, nil))
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "node-1"}}); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			node := getNode(t, r, "node-1")
+			if inProgress := r.isNodeBeingDrained(node); inProgress == tt.cleared {
+				t.Errorf("Expected drain-in-progress %v, got %v", !tt.cleared, inProgress)
+			}
+			if _, ok := node.Annotations["draino2.kubernetes.io/drain-heartbeat"]; ok == tt.cleared {
+				t.Errorf("Expected drain-heartbeat present %v, got %v", !tt.cleared, ok)
+			}
+		})
+	}
+}
+
+func TestRefreshHeartbeat(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		heartbeat time.Time
+		refreshed bool
+	}{
+		{name: "recent heartbeat", heartbeat: now.Add(-10 * time.Second), refreshed: false},
+		{name: "old heartbeat", heartbeat: now.Add(-2 * time.Minute), refreshed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestController(t, &types.Config{},
+				newDrainingNode("test-instance", now.Add(-time.Hour), tt.heartbeat, nil))
+
+			node := getNode(t, r, "node-1")
+			if err := r.refreshHeartbeat(context.Background(), node, now); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			expected := tt.heartbeat.UTC().Format(time.RFC3339)
+			if tt.refreshed {
+				expected = now.UTC().Format(time.RFC3339)
+			}
+			node = getNode(t, r, "node-1")
+			if heartbeat := node.Annotations["draino2.kubernetes.io/drain-heartbeat"]; heartbeat != expected {
+				t.Errorf("Expected heartbeat %s, got %s", expected, heartbeat)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultDrainRetryInterval is the wait before retrying a failed drain when
+// no retry interval is configured
+const defaultDrainRetryInterval = 5 * time.Minute
+
+// drainStuckError is the cause of a drain stopped for exceeding the maximum drain duration
+type drainStuckError struct {
+	age         time.Duration
+	maxDuration time.Duration
+}
+
+// Error implements the error interface
+func (e *drainStuckError) Error() string {
+	return fmt.Sprintf("drain has been running for %s, exceeding the maximum drain duration of %s",
+		e.age.Round(time.Second), e.maxDuration)
+}
+
+// checkDrainStuck compares the node's drain-start-time against the maximum
+// drain duration and returns a drainStuckError if it has been exceeded
+func (r *DrainController) checkDrainStuck(node *corev1.Node, now time.Time) *drainStuckError {
+	maxDuration := r.Config.DrainSettings.MaxDrainDuration
+	if maxDuration <= 0 {
+		return nil
+	}
+	age, known := drainAge(node, now)
+	if !known || age <= maxDuration {
+		return nil
+	}
+	return &drainStuckError{age: age, maxDuration: maxDuration}
+}
+
+// handleDrainStuck reports a drain that exceeded the maximum drain duration
+// and moves the node to the Failed state
+func (r *DrainController) handleDrainStuck(ctx context.Context, node *corev1.Node, reason string, pods []string, stuck *drainStuckError) (ctrl.Result, error) {
+	klog.FromContext(ctx).Info("Drain is stuck", "node", node.Name, "age", stuck.age, "maxDrainDuration", stuck.maxDuration)
+
+	r.Recorder.Eventf(node, corev1.EventTypeWarning, "DrainStuck",
+		"Drain of node %s stopped: %v", node.Name, stuck)
+	if r.Metrics != nil {
+		r.Metrics.DrainOperationsStuck.Inc()
+	}
+
+	return r.failDrain(ctx, node, reason, pods, stuck)
+}
+
+// drainRetryDue applies the retry policy to a failed drain. It reports whether
+// the drain may be retried now and, if not, how long until it may be; a zero
+// wait with no retry means the retries are exhausted.
+func (r *DrainController) drainRetryDue(node *corev1.Node, now time.Time) (bool, time.Duration) {
+	policy := r.Config.DrainSettings.DrainRetry
+	if drainRetries(node) >= policy.MaxRetries {
+		return false, 0
+	}
+
+	interval := policy.RetryInterval
+	if interval <= 0 {
+		interval = defaultDrainRetryInterval
+	}
+	failedAt, err := time.Parse(time.RFC3339, node.Annotations["draino2.kubernetes.io/drain-failed-time"])
+	if err != nil {
+		return true, 0
+	}
+	if wait := failedAt.Add(interval).Sub(now); wait > 0 {
+		return false, wait
+	}
+	return true, 0
+}
+
+// drainRetries returns how often a failed drain of the node has been retried
+func drainRetries(node *corev1.Node) int {
+	retries, err := strconv.Atoi(node.Annotations["draino2.kubernetes.io/drain-retries"])
+	if err != nil {
+		return 0
+	}
+	return retries
+}
+
+// clearDrainFailed removes the Failed state, either to retry the drain, which
+// counts against the retry policy, or because the trigger was removed, which
+// resets the count
+func (r *DrainController) clearDrainFailed(node *corev1.Node, retry bool) error {
+	patch := client.MergeFrom(node.DeepCopy())
+
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+
+	if retry {
+		node.Annotations["draino2.kubernetes.io/drain-retries"] = strconv.Itoa(drainRetries(node) + 1)
+	} else {
+		delete(node.Annotations, "draino2.kubernetes.io/drain-retries")
+	}
+	delete(node.Annotations, "draino2.kubernetes.io/drain-failed")
+	delete(node.Annotations, "draino2.kubernetes.io/drain-failed-time")
+
+	return r.Patch(context.Background(), node, patch)
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+func TestCheckDrainStuck(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		maxDuration time.Duration
+		startTime   string
+		expected    bool
+	}{
+		{"no maximum", 0, now.Add(-time.Hour).UTC().Format(time.RFC3339), false},
+		{"within maximum", time.Hour, now.Add(-30 * time.Minute).UTC().Format(time.RFC3339), false},
+		{"exceeds maximum", time.Hour, now.Add(-2 * time.Hour).UTC().Format(time.RFC3339), true},
+		{"unknown start time", time.Hour, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &DrainController{Config: &types.Config{DrainSettings: types.DrainSettings{
+				MaxDrainDuration: tt.maxDuration,
+			}}}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				"draino2.kubernetes.io/drain-in-progress": "true",
+				"draino2.kubernetes.io/drain-start-time":  tt.startTime,
+			}}}
+
+			stuck := r.checkDrainStuck(node, now)
+			if (stuck != nil) != tt.expected {
+				t.Fatalf("Expected stuck %t, got %v", tt.expected, stuck)
+			}
+			if stuck != nil && stuck.maxDuration != tt.maxDuration {
+				t.Errorf("Expected maximum drain duration %s, got %s", tt.maxDuration, stuck.maxDuration)
+			}
+		})
+	}
+}
+
+func TestHandleDrainStuck(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		requeue    time.Duration
+	}{
+		{name: "retries left", maxRetries: 1, requeue: 10 * time.Minute},
+		{name: "no retries left", maxRetries: 0, requeue: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.Config{DrainSettings: types.DrainSettings{
+				MaxDrainDuration: time.Hour,
+				DrainRetry:       types.DrainRetry{MaxRetries: tt.maxRetries, RetryInterval: 10 * time.Minute},
+			}}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+				Annotations: map[string]string{
+					"draino2.kubernetes.io/drain-in-progress": "true",
+					"draino2.kubernetes.io/drain-start-time":  time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+					"draino2.kubernetes.io/drain-owner":       "test-instance",
+				},
+			}}
+			r, _ := newTestController(t, config, node)
+
+			current := getNode(t, r, "node-1")
+			stuck := r.checkDrainStuck(current, time.Now())
+			if stuck == nil {
+				t.Fatal("Expected the drain to be stuck")
+			}
+			result, err := r.handleDrainStuck(context.Background(), current, "test", nil, stuck)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.RequeueAfter > tt.requeue || (tt.requeue > 0 && result.RequeueAfter < tt.requeue-time.Minute) {
+				t.Errorf("Expected requeue after about %s, got %s", tt.requeue, result.RequeueAfter)
+			}
+
+			current = getNode(t, r, "node-1")
+			if r.isNodeBeingDrained(current) {
+				t.Error("Expected drain-in-progress to be removed")
+			}
+			if failure := current.Annotations["draino2.kubernetes.io/drain-failed"]; !strings.Contains(failure, "maximum drain duration") {
+				t.Errorf("Expected drain-failed to hold the stuck reason, got %q", failure)
+			}
+
+			select {
+			case event := <-r.Recorder.(*record.FakeRecorder).Events:
+				if !strings.Contains(event, "DrainStuck") {
+					t.Errorf("Expected a DrainStuck event, got %q", event)
+				}
+			default:
+				t.Error("Expected a DrainStuck event")
+			}
+		})
+	}
+}
+
+func TestDrainRetryDue(t *testing.T) {
+	now := time.Now()
+	r := &DrainController{Config: &types.Config{DrainSettings: types.DrainSettings{
+		DrainRetry: types.DrainRetry{MaxRetries: 2, RetryInterval: 5 * time.Minute},
+	}}}
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		"draino2.kubernetes.io/drain-failed":      "boom",
+		"draino2.kubernetes.io/drain-failed-time": now.Add(-2 * time.Minute).UTC().Format(time.RFC3339),
+	}}}
+	if retry, wait := r.drainRetryDue(node, now); retry || wait <= 0 || wait > 3*time.Minute {
+		t.Errorf("Expected to wait about 3m before retrying, got retry=%t wait=%s", retry, wait)
+	}
+
+	if retry, _ := r.drainRetryDue(node, now.Add(4*time.Minute)); !retry {
+		t.Error("Expected retry once the interval has passed")
+	}
+
+	node.Annotations["draino2.kubernetes.io/drain-retries"] = "2"
+	if retry, wait := r.drainRetryDue(node, now.Add(time.Hour)); retry || wait != 0 {
+		t.Errorf("Expected no retries left, got retry=%t wait=%s", retry, wait)
+	}
+}
@@ -0,0 +1,54 @@
+package drainer
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EvictAnnotation lets application teams control eviction of individual pods
+	EvictAnnotation = "draino2.kubernetes.io/evict"
+	// SafeToEvictAnnotation is the cluster-autoscaler annotation, honored for compatibility
+	SafeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+)
+
+// Values of EvictAnnotation
+const (
+	// EvictNever blocks the drain of the node the pod runs on
+	EvictNever = "never"
+	// EvictLast evicts the pod after every other pod on the node has terminated
+	EvictLast = "last"
+	// EvictAlways evicts the pod even if later filters would skip it
+	EvictAlways = "always"
+)
+
+// evictAnnotation returns the pod's eviction preference. EvictAnnotation takes
+// precedence over SafeToEvictAnnotation, which maps "false" to EvictNever and
+// "true" to EvictAlways.
+func evictAnnotation(pod *corev1.Pod) string {
+	switch pod.Annotations[EvictAnnotation] {
+	case EvictNever, EvictLast, EvictAlways:
+		return pod.Annotations[EvictAnnotation]
+	}
+
+	switch pod.Annotations[SafeToEvictAnnotation] {
+	case "false":
+		return EvictNever
+	case "true":
+		return EvictAlways
+	}
+
+	return ""
+}
+
+// filterAnnotations applies the pod's own eviction preference
+func filterAnnotations(_ context.Context, pod *corev1.Pod) FilterResult {
+	switch evictAnnotation(pod) {
+	case EvictNever:
+		return block("pod is annotated to never be evicted")
+	case EvictAlways:
+		return FilterResult{Action: FilterForceInclude, Reason: "pod is annotated to always be evicted"}
+	}
+	return include
+}
@@ -0,0 +1,367 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// InsufficientCapacityError is returned when the pods that would be evicted
+// from a node do not fit on the remaining schedulable nodes
+type InsufficientCapacityError struct {
+	Node string
+	Pods []string
+}
+
+// Error implements the error interface
+func (e *InsufficientCapacityError) Error() string {
+	return fmt.Sprintf("insufficient capacity to reschedule %d pod(s) from node %s: %s",
+		len(e.Pods), e.Node, strings.Join(e.Pods, "; "))
+}
+
+// simulatedNode is a candidate node with the resources and pods it would
+// have after the simulated rescheduling
+type simulatedNode struct {
+	node *corev1.Node
+	free corev1.ResourceList
+	pods []*corev1.Pod
+}
+
+// CheckCapacity simulates rescheduling the pods that would be evicted from a
+// node onto the other schedulable nodes. It takes resource requests,
+// nodeSelector, required node affinity, taints and tolerations, and required
+// pod anti-affinity into account, and returns an InsufficientCapacityError
+// listing the pods that would not fit.
+func (d *Drainer) CheckCapacity(ctx context.Context, node *corev1.Node) error {
+	log := klog.FromContext(ctx)
+
+	pods, _, _, err := d.getPodsOnNode(ctx, node.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get pods on node: %w", err)
+	}
+
+	// DaemonSet pods are recreated on the same node, not rescheduled elsewhere
+	var toPlace []*corev1.Pod
+	for i := range pods {
+		if owner := metav1.GetControllerOf(&pods[i]); owner != nil && owner.Kind == "DaemonSet" {
+			continue
+		}
+		toPlace = append(toPlace, &pods[i])
+	}
+	if len(toPlace) == 0 {
+		return nil
+	}
+
+	candidates, err := d.simulatedNodes(ctx, node.Name)
+	if err != nil {
+		return err
+	}
+
+	// Place the largest pods first, which packs better than arrival order
+	sort.SliceStable(toPlace, func(i, j int) bool {
+		ri, rj := podRequests(toPlace[i]), podRequests(toPlace[j])
+		if c := ri.Cpu().Cmp(*rj.Cpu()); c != 0 {
+			return c > 0
+		}
+		return ri.Memory().Cmp(*rj.Memory()) > 0
+	})
+
+	var unplaced []string
+	for _, pod := range toPlace {
+		requests := podRequests(pod)
+		reasons := make(map[string]int)
+
+		placed := false
+		for _, candidate := range candidates {
+			if reason := candidate.fits(pod, requests, candidates); reason != "" {
+				reasons[reason]++
+				continue
+			}
+			candidate.place(pod, requests)
+			placed = true
+			break
+		}
+
+		if !placed {
+			unplaced = append(unplaced, fmt.Sprintf("%s/%s (%s)", pod.Namespace, pod.Name, formatFitReasons(reasons, len(candidates))))
+		}
+	}
+
+	if len(unplaced) > 0 {
+		return &InsufficientCapacityError{Node: node.Name, Pods: unplaced}
+	}
+
+	log.V(2).Info("Capacity check passed", "node", node.Name, "podCount", len(toPlace), "candidateNodes", len(candidates))
+	return nil
+}
+
+// simulatedNodes lists the schedulable, ready nodes other than the one being
+// drained, together with the resources their current pods leave free
+func (d *Drainer) simulatedNodes(ctx context.Context, drainingNode string) ([]*simulatedNode, error) {
+	nodes, err := d.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	byName := make(map[string]*simulatedNode)
+	var candidates []*simulatedNode
+	for i := range nodes.Items {
+		n := &nodes.Items[i]
+		if n.Name == drainingNode || n.Spec.Unschedulable || !isNodeReady(n) {
+			continue
+		}
+		candidate := &simulatedNode{node: n, free: n.Status.Allocatable.DeepCopy()}
+		byName[n.Name] = candidate
+		candidates = append(candidates, candidate)
+	}
+
+	// Subtract what running pods already request
+	fieldSelector := fields.AndSelectors(
+		fields.OneTermNotEqualSelector("status.phase", string(corev1.PodSucceeded)),
+		fields.OneTermNotEqualSelector("status.phase", string(corev1.PodFailed)),
+	)
+	pods, err := d.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{FieldSelector: fieldSelector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if candidate, ok := byName[pod.Spec.NodeName]; ok {
+			candidate.place(pod, podRequests(pod))
+		}
+	}
+
+	return candidates, nil
+}
+
+// place records a pod on the node and subtracts its requests
+func (n *simulatedNode) place(pod *corev1.Pod, requests corev1.ResourceList) {
+	for name, quantity := range requests {
+		free := n.free[name]
+		free.Sub(quantity)
+		n.free[name] = free
+	}
+	n.pods = append(n.pods, pod)
+}
+
+// fits returns why the pod cannot be placed on the node, or "" if it can
+func (n *simulatedNode) fits(pod *corev1.Pod, requests corev1.ResourceList, all []*simulatedNode) string {
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(n.node.Labels)) {
+		return "node selector mismatch"
+	}
+	if !matchesRequiredNodeAffinity(pod, n.node) {
+		return "node affinity mismatch"
+	}
+	if !toleratesTaints(pod, n.node.Spec.Taints) {
+		return "untolerated taint"
+	}
+	for name, quantity := range requests {
+		free, ok := n.free[name]
+		if !ok || free.Cmp(quantity) < 0 {
+			return "insufficient " + string(name)
+		}
+	}
+	if violatesPodAntiAffinity(pod, n.node, all) {
+		return "pod anti-affinity"
+	}
+	return ""
+}
+
+// podRequests returns the resources a pod needs to be scheduled: the sum of
+// its containers' requests or the largest init container request, whichever
+// is larger, plus pod overhead and one pod slot
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	addResources(requests, pod.Spec.Overhead)
+	addResources(requests, corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI)})
+	return requests
+}
+
+// addResources adds every quantity in src to dst
+func addResources(dst, src corev1.ResourceList) {
+	for name, quantity := range src {
+		current := dst[name]
+		current.Add(quantity)
+		dst[name] = current
+	}
+}
+
+// isNodeReady reports whether the node's Ready condition is True
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// toleratesTaints reports whether the pod tolerates every NoSchedule and
+// NoExecute taint on a node
+func toleratesTaints(pod *corev1.Pod, taints []corev1.Taint) bool {
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRequiredNodeAffinity evaluates the pod's required node affinity
+// terms, which are ORed, against a node
+func matchesRequiredNodeAffinity(pod *corev1.Pod, node *corev1.Node) bool {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for _, term := range terms {
+		if matchesNodeSelectorTerm(term, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeSelectorTerm evaluates one node selector term, whose requirements are ANDed
+func matchesNodeSelectorTerm(term corev1.NodeSelectorTerm, node *corev1.Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, req := range term.MatchExpressions {
+		value, exists := node.Labels[req.Key]
+		if !matchesNodeSelectorRequirement(req, value, exists) {
+			return false
+		}
+	}
+	for _, req := range term.MatchFields {
+		if req.Key != "metadata.name" || !matchesNodeSelectorRequirement(req, node.Name, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesNodeSelectorRequirement evaluates a single node selector requirement against a value
+func matchesNodeSelectorRequirement(req corev1.NodeSelectorRequirement, value string, exists bool) bool {
+	switch req.Operator {
+	case corev1.NodeSelectorOpIn:
+		return exists && containsString(req.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !exists || !containsString(req.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return exists
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !exists
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !exists || len(req.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		bound, err := strconv.ParseInt(req.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if req.Operator == corev1.NodeSelectorOpGt {
+			return actual > bound
+		}
+		return actual < bound
+	}
+	return false
+}
+
+// violatesPodAntiAffinity reports whether placing the pod on a node would put
+// it in the same topology domain as a pod its required anti-affinity excludes
+func violatesPodAntiAffinity(pod *corev1.Pod, node *corev1.Node, all []*simulatedNode) bool {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		domain, ok := node.Labels[term.TopologyKey]
+		if !ok {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+		if err != nil {
+			continue
+		}
+		namespaces := term.Namespaces
+		if len(namespaces) == 0 && term.NamespaceSelector == nil {
+			namespaces = []string{pod.Namespace}
+		}
+
+		for _, other := range all {
+			if other.node.Labels[term.TopologyKey] != domain {
+				continue
+			}
+			for _, existing := range other.pods {
+				if len(namespaces) > 0 && !containsString(namespaces, existing.Namespace) {
+					continue
+				}
+				if selector.Matches(labels.Set(existing.Labels)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// formatFitReasons summarizes why a pod fit on none of the candidate nodes
+func formatFitReasons(reasons map[string]int, candidates int) string {
+	if candidates == 0 {
+		return "no schedulable nodes"
+	}
+	parts := make([]string, 0, len(reasons))
+	for reason, count := range reasons {
+		parts = append(parts, fmt.Sprintf("%d node(s) %s", count, reason))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// containsString reports whether a slice contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
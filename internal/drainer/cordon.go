@@ -0,0 +1,200 @@
+package drainer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CordonMode selects how a node is cordoned
+type CordonMode string
+
+const (
+	// CordonModeUnschedulable sets spec.unschedulable, like kubectl cordon
+	CordonModeUnschedulable CordonMode = "unschedulable"
+	// CordonModeTaint adds a draino2-owned taint, so nodes cordoned by
+	// draino2 can be told apart from nodes cordoned by hand
+	CordonModeTaint CordonMode = "taint"
+)
+
+// DefaultCordonTaintKey is the key of the taint added in CordonModeTaint
+const DefaultCordonTaintKey = "draino2.kubernetes.io/draining"
+
+// CordonedByAnnotation marks a node that draino2 cordoned itself, so that
+// Uncordon never reopens a node that someone else cordoned
+const CordonedByAnnotation = "draino2.kubernetes.io/cordoned-by-draino2"
+
+// nodeUpdateRetries bounds how often a node update is retried on conflict
+const nodeUpdateRetries = 5
+
+// CordonNotOwnedError is returned when asked to uncordon a node that draino2
+// did not cordon
+type CordonNotOwnedError struct {
+	Node string
+}
+
+// Error implements the error interface
+func (e *CordonNotOwnedError) Error() string {
+	return fmt.Sprintf("node %s was not cordoned by draino2, refusing to uncordon without force", e.Node)
+}
+
+// ValidateCordonMode checks that mode is empty or a known CordonMode
+func ValidateCordonMode(mode CordonMode) error {
+	switch mode {
+	case "", CordonModeUnschedulable, CordonModeTaint:
+		return nil
+	}
+	return fmt.Errorf("unknown cordon mode %q", mode)
+}
+
+// cordonTaints returns the taints draino2 adds to a node in CordonModeTaint
+func (d *Drainer) cordonTaints() []corev1.Taint {
+	taint := d.config.CordonTaint
+	if taint.Key == "" {
+		taint.Key = DefaultCordonTaintKey
+	}
+	if taint.Effect == "" {
+		taint.Effect = corev1.TaintEffectNoSchedule
+	}
+
+	taints := []corev1.Taint{taint}
+	if d.config.CordonNoExecute && taint.Effect != corev1.TaintEffectNoExecute {
+		taints = append(taints, corev1.Taint{Key: taint.Key, Value: taint.Value, Effect: corev1.TaintEffectNoExecute})
+	}
+	return taints
+}
+
+// IsCordoned reports whether the node is cordoned in the configured mode
+func (d *Drainer) IsCordoned(node *corev1.Node) bool {
+	if d.config.CordonMode != CordonModeTaint {
+		return node.Spec.Unschedulable
+	}
+	for _, taint := range d.cordonTaints() {
+		if !hasTaint(node.Spec.Taints, taint) {
+			return false
+		}
+	}
+	return true
+}
+
+// ownsCordon reports whether draino2 cordoned the node. In CordonModeTaint
+// the taint is draino2's own, so removing it never reverts someone else's cordon.
+func (d *Drainer) ownsCordon(node *corev1.Node) bool {
+	if d.config.CordonMode == CordonModeTaint {
+		return true
+	}
+	return node.Annotations[CordonedByAnnotation] == "true"
+}
+
+// ForgetCordon drops CordonedByAnnotation from a node that is no longer
+// cordoned, so that someone else cordoning it later is not mistaken for
+// draino2's cordon. The patch is conditional on the node's resourceVersion
+// and a conflict, meaning the node changed meanwhile, is ignored.
+func (d *Drainer) ForgetCordon(ctx context.Context, node *corev1.Node) error {
+	if _, ok := node.Annotations[CordonedByAnnotation]; !ok || d.IsCordoned(node) || d.config.DryRun {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": node.ResourceVersion,
+			"annotations":     map[string]interface{}{CordonedByAnnotation: nil},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = d.client.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !errors.IsConflict(err) {
+		return fmt.Errorf("failed to remove %s: %w", CordonedByAnnotation, err)
+	}
+	delete(node.Annotations, CordonedByAnnotation)
+	return nil
+}
+
+// setCordoned cordons or uncordons the node in the configured mode, recording
+// in CordonedByAnnotation that draino2 made the change
+func (d *Drainer) setCordoned(ctx context.Context, nodeName string, cordoned bool) error {
+	var owner interface{}
+	if cordoned {
+		owner = "true"
+	}
+
+	if d.config.CordonMode != CordonModeTaint {
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{CordonedByAnnotation: owner},
+			},
+			"spec": map[string]interface{}{"unschedulable": cordoned},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = d.client.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		return err
+	}
+
+	owned := d.cordonTaints()
+	return d.updateNode(ctx, nodeName, func(node *corev1.Node) {
+		// Always drop our own taints first so that a changed value is replaced
+		var taints []corev1.Taint
+		for _, taint := range node.Spec.Taints {
+			if !hasTaint(owned, taint) {
+				taints = append(taints, taint)
+			}
+		}
+
+		if !cordoned {
+			node.Spec.Taints = taints
+			delete(node.Annotations, CordonedByAnnotation)
+			return
+		}
+
+		now := metav1.Now()
+		for _, taint := range owned {
+			if taint.Effect == corev1.TaintEffectNoExecute {
+				taint.TimeAdded = &now
+			}
+			taints = append(taints, taint)
+		}
+		node.Spec.Taints = taints
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[CordonedByAnnotation] = "true"
+	})
+}
+
+// updateNode applies mutate to the current node and updates it, retrying
+// when the node was modified concurrently
+func (d *Drainer) updateNode(ctx context.Context, nodeName string, mutate func(*corev1.Node)) error {
+	var err error
+	for attempt := 0; attempt < nodeUpdateRetries; attempt++ {
+		var node *corev1.Node
+		node, err = d.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(node)
+		_, err = d.client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		if !errors.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// hasTaint reports whether taints contains one with the same key and effect
+func hasTaint(taints []corev1.Taint, taint corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,10 @@ package drainer
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -11,59 +14,126 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+
+	"github.com/nfelsen/draino2/internal/metrics"
 )
 
 // Drainer handles cordoning and draining operations on nodes
 type Drainer struct {
 	client   kubernetes.Interface
 	recorder record.EventRecorder
+	metrics  *metrics.Metrics
 	config   *DrainerConfig
+	filters  []PodFilter
+
+	plansLock sync.RWMutex
+	plans     map[string]*DrainPlan
 }
 
 // DrainerConfig holds configuration for the drainer
 type DrainerConfig struct {
-	// GracePeriod is the grace period for pod termination
+	// GracePeriod is the maximum grace period for pod termination. Pods are
+	// evicted with their own terminationGracePeriodSeconds, capped at this value.
 	GracePeriod time.Duration
+	// EvictionHeadroom is the extra time, on top of GracePeriod, an evicted pod
+	// may take to terminate before the EscalationPolicy is applied
+	EvictionHeadroom time.Duration
+	// EscalationPolicy controls how evictions that never complete are escalated
+	EscalationPolicy EscalationPolicy
 	// Timeout is the maximum time to wait for drain to complete
 	Timeout time.Duration
+	// CordonMode selects how nodes are cordoned. CordonModeUnschedulable is used when empty.
+	CordonMode CordonMode
+	// CordonTaint is the taint added in CordonModeTaint. The key defaults to
+	// DefaultCordonTaintKey and the effect to NoSchedule.
+	CordonTaint corev1.Taint
+	// CordonNoExecute also adds CordonTaint with the NoExecute effect
+	CordonNoExecute bool
+	// DryRun computes a DrainPlan instead of cordoning, evicting or uncordoning
+	DryRun bool
 	// Force forces the drain even if there are pods that cannot be evicted
 	Force bool
 	// IgnoreDaemonSets ignores DaemonSet-managed pods
 	IgnoreDaemonSets bool
-	// DeleteEmptyDirData allows deletion of pods with emptyDir volumes
+	// EvictUnreplicatedPods allows eviction of pods without a controller, or whose controller is gone
+	EvictUnreplicatedPods bool
+	// BlockOnUnreplicatedPods refuses to drain a node running unreplicated pods instead of skipping them
+	BlockOnUnreplicatedPods bool
+	// DeleteEmptyDirData allows deletion of pods with disk-backed emptyDir volumes
 	DeleteEmptyDirData bool
+	// DeleteMemoryEmptyDirData allows deletion of pods with memory-backed emptyDir volumes
+	DeleteMemoryEmptyDirData bool
+	// EvictHostPathPods allows eviction of pods with hostPath volumes
+	EvictHostPathPods bool
+	// EvictLocalPVPods allows eviction of pods using local PersistentVolumes
+	EvictLocalPVPods bool
 	// PodSelector filters which pods to evict
 	PodSelector labels.Selector
+	// PodFilters names the filters, in order, that decide whether a pod is
+	// evicted. DefaultPodFilters is used when empty.
+	PodFilters []string
+	// EvictionTiers orders evictions into waves. DefaultEvictionTiers is used when empty.
+	EvictionTiers []EvictionTier
+	// MaxConcurrentEvictions limits how many pods are evicted in parallel
+	MaxConcurrentEvictions int
+	// StatefulSetReadyTimeout bounds the time a drain spends waiting for
+	// StatefulSets to report evicted replicas' replacements Ready, counted from
+	// the start of the drain's evictions
+	StatefulSetReadyTimeout time.Duration
+	// RollingEviction evicts the pods of each ReplicaSet one at a time, waiting
+	// for its available replicas to recover before evicting the next pod
+	RollingEviction bool
+	// RollingEvictionTimeout is how long to wait for a ReplicaSet to recover in rolling mode
+	RollingEvictionTimeout time.Duration
+	// VolumeDetachTimeout is how long WaitForVolumeDetach waits for a node's VolumeAttachments to go away
+	VolumeDetachTimeout time.Duration
+	// RetryBackoff is the initial delay before retrying an eviction blocked by a PodDisruptionBudget
+	RetryBackoff time.Duration
+	// MaxRetryBackoff caps the delay between eviction retries
+	MaxRetryBackoff time.Duration
+	// RetryBackoffFactor multiplies the delay after each blocked eviction
+	RetryBackoffFactor float64
 }
 
+const (
+	defaultRetryBackoff       = 5 * time.Second
+	defaultMaxRetryBackoff    = 1 * time.Minute
+	defaultRetryBackoffFactor = 2.0
+)
+
 // NewDrainer creates a new drainer instance
-func NewDrainer(client kubernetes.Interface, recorder record.EventRecorder, config *DrainerConfig) *Drainer {
-	return &Drainer{
+func NewDrainer(client kubernetes.Interface, recorder record.EventRecorder, metrics *metrics.Metrics, config *DrainerConfig) *Drainer {
+	d := &Drainer{
 		client:   client,
 		recorder: recorder,
+		metrics:  metrics,
 		config:   config,
+		plans:    make(map[string]*DrainPlan),
 	}
+	d.filters = buildPodFilters(d, config.PodFilters)
+	return d
 }
 
-// Cordon marks a node as unschedulable
+// Cordon marks a node as unschedulable, or taints it in CordonModeTaint
 func (d *Drainer) Cordon(ctx context.Context, node *corev1.Node) error {
 	log := klog.FromContext(ctx)
 	log.Info("Cordoning node", "node", node.Name)
 
 	// Check if node is already cordoned
-	if node.Spec.Unschedulable {
+	if d.IsCordoned(node) {
 		log.Info("Node is already cordoned", "node", node.Name)
 		return nil
 	}
 
-	// Create a patch to mark the node as unschedulable
-	patch := []byte(`{"spec":{"unschedulable":true}}`)
+	if d.config.DryRun {
+		log.Info("Dry run: would cordon node", "node", node.Name)
+		return nil
+	}
 
-	_, err := d.client.CoreV1().Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	err := d.setCordoned(ctx, node.Name, true)
 	if err != nil {
 		log.Error(err, "Failed to cordon node", "node", node.Name)
 		d.recorder.Eventf(node, corev1.EventTypeWarning, "CordonFailed",
@@ -78,17 +148,49 @@ func (d *Drainer) Cordon(ctx context.Context, node *corev1.Node) error {
 	return nil
 }
 
-// Drain evicts all pods from a node
+// Drain evicts all pods from a node and waits for them to terminate.
+// The whole operation, including the wait, is bounded by DrainerConfig.Timeout.
+// In dry-run mode it only computes and records a DrainPlan.
 func (d *Drainer) Drain(ctx context.Context, node *corev1.Node) error {
 	log := klog.FromContext(ctx)
 	log.Info("Starting drain operation", "node", node.Name)
 
+	if d.config.DryRun {
+		plan, err := d.Plan(ctx, node, false)
+		if err != nil {
+			return fmt.Errorf("failed to plan drain: %w", err)
+		}
+		d.RecordPlan(ctx, node, plan)
+		return nil
+	}
+
+	// parent is kept to tell a stopped drain apart from the drain timing out
+	parent := ctx
+	if d.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.Timeout)
+		defer cancel()
+	}
+
 	// Get all pods on the node
-	pods, err := d.getPodsOnNode(ctx, node.Name)
+	pods, skipped, blocked, err := d.getPodsOnNode(ctx, node.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get pods on node: %w", err)
 	}
 
+	if len(blocked) > 0 {
+		blockedErr := &DrainBlockedError{Node: node.Name, Pods: podReasons(blocked)}
+		log.Info("Refusing to drain node", "node", node.Name, "blockedPods", len(blocked))
+		d.recorder.Eventf(node, corev1.EventTypeWarning, "DrainRefused", "%s", blockedErr.Error())
+		for _, b := range blocked {
+			d.recorder.Eventf(&b.pod, corev1.EventTypeWarning, "DrainBlockedByPod",
+				"Pod %s/%s is blocking the drain of node %s: %s", b.pod.Namespace, b.pod.Name, node.Name, b.reason)
+		}
+		return blockedErr
+	}
+
+	d.recordSkippedPods(node, skipped)
+
 	if len(pods) == 0 {
 		log.Info("No pods to drain on node", "node", node.Name)
 		return nil
@@ -96,21 +198,46 @@ func (d *Drainer) Drain(ctx context.Context, node *corev1.Node) error {
 
 	log.Info("Found pods to drain", "node", node.Name, "podCount", len(pods))
 
-	// Evict pods
+	// Evict pods in waves by tier, waiting for each wave to terminate before starting the next
+	start := time.Now()
+	waves := d.evictionWaves(pods)
 	evictedPods := 0
 	failedPods := 0
 
-	for _, pod := range pods {
-		if err := d.evictPod(ctx, &pod); err != nil {
-			log.Error(err, "Failed to evict pod", "node", node.Name, "pod", pod.Name, "namespace", pod.Namespace)
-			failedPods++
+	for i, wave := range waves {
+		log.Info("Evicting pods", "node", node.Name, "wave", i+1, "waves", len(waves), "tier", wave.name, "podCount", len(wave.pods))
+		evicted, failures := d.evictPods(ctx, wave.pods, start)
+		failedPods += len(failures)
 
-			if !d.config.Force {
-				return fmt.Errorf("failed to evict pod %s/%s: %w", pod.Namespace, pod.Name, err)
-			}
-		} else {
-			evictedPods++
+		for _, failure := range failures {
+			log.Error(failure.err, "Failed to evict pod", "node", node.Name, "pod", failure.pod.Name, "namespace", failure.pod.Namespace)
+		}
+
+		if len(failures) > 0 && !d.config.Force {
+			first := failures[0]
+			return fmt.Errorf("failed to evict pod %s/%s: %w", first.pod.Namespace, first.pod.Name, first.err)
+		}
+
+		// Wait for evicted pods to terminate
+		log.Info("Waiting for evicted pods to terminate", "node", node.Name, "tier", wave.name, "podCount", len(evicted))
+		remaining, err := d.waitForPodsDeleted(ctx, evicted)
+		if err != nil && parent.Err() != nil {
+			// The drain was cancelled or stopped, which the caller reports
+			log.Info("Drain stopped while waiting for evicted pods to terminate", "node", node.Name, "remainingPods", len(remaining))
+			return parent.Err()
 		}
+		if err != nil && !stderrors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("failed waiting for evicted pods to terminate: %w", err)
+		}
+		if err != nil {
+			timeoutErr := &PodsNotTerminatedError{Node: node.Name, Pods: podNames(remaining)}
+			log.Error(timeoutErr, "Evicted pods did not terminate in time", "node", node.Name, "remainingPods", len(remaining))
+			d.recorder.Eventf(node, corev1.EventTypeWarning, "DrainTimeout",
+				"Timed out waiting for %d pod(s) to terminate on node %s: %s",
+				len(remaining), node.Name, strings.Join(timeoutErr.Pods, ", "))
+			return timeoutErr
+		}
+		evictedPods += len(evicted)
 	}
 
 	log.Info("Drain operation completed", "node", node.Name, "evictedPods", evictedPods, "failedPods", failedPods)
@@ -126,74 +253,200 @@ func (d *Drainer) Drain(ctx context.Context, node *corev1.Node) error {
 	return nil
 }
 
-// getPodsOnNode gets all pods running on the specified node
-func (d *Drainer) getPodsOnNode(ctx context.Context, nodeName string) ([]corev1.Pod, error) {
+// evictionFailure records a pod that could not be evicted
+type evictionFailure struct {
+	pod corev1.Pod
+	err error
+}
+
+// evictPods evicts pods using a pool of at most MaxConcurrentEvictions workers.
+// Each worker takes one eviction group, so the replicas of a StatefulSet, or of
+// a ReplicaSet in rolling mode, are evicted serially while unrelated pods are
+// evicted in parallel. Unless Force
+// is set, the first failure cancels evictions still in flight and stops new
+// ones from starting, so the first entry in the returned failures is the one
+// that aborted the drain. start is when the drain began evicting pods.
+func (d *Drainer) evictPods(ctx context.Context, pods []corev1.Pod, start time.Time) ([]*evictedPod, []evictionFailure) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		evicted  []*evictedPod
+		failures []evictionFailure
+	)
+
+	// Pods that never got a worker because the drain was aborted or timed out
+	var notStarted []corev1.Pod
+
+	groups := d.evictionGroups(pods)
+	sem := make(chan struct{}, d.maxConcurrentEvictions())
+	for i, group := range groups {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			for _, g := range groups[i:] {
+				notStarted = append(notStarted, g.pods...)
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(group evictionGroup) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			groupEvicted, groupFailures := d.evictGroup(ctx, group, start)
+
+			mu.Lock()
+			defer mu.Unlock()
+			evicted = append(evicted, groupEvicted...)
+			if len(groupFailures) > 0 {
+				failures = append(failures, groupFailures...)
+				if !d.config.Force {
+					cancel()
+				}
+			}
+		}(group)
+	}
+	wg.Wait()
+
+	failures = append(failures, notAttempted(notStarted, ctx.Err())...)
+
+	return evicted, failures
+}
+
+// maxConcurrentEvictions returns the size of the eviction worker pool
+func (d *Drainer) maxConcurrentEvictions() int {
+	if d.config.MaxConcurrentEvictions > 0 {
+		return d.config.MaxConcurrentEvictions
+	}
+	return 1
+}
+
+// skippedPod records a pod that was not evicted and why
+type skippedPod struct {
+	pod    corev1.Pod
+	reason string
+}
+
+// DrainBlockedError is returned when pods on a node prevent it from being drained
+type DrainBlockedError struct {
+	Node string
+	Pods []string
+}
+
+// Error implements the error interface
+func (e *DrainBlockedError) Error() string {
+	return fmt.Sprintf("refusing to drain node %s, blocked by %d pod(s): %s",
+		e.Node, len(e.Pods), strings.Join(e.Pods, "; "))
+}
+
+// PodsToEvict returns the namespace/name of every pod on the node that a
+// drain would currently evict
+func (d *Drainer) PodsToEvict(ctx context.Context, nodeName string) ([]string, error) {
+	pods, _, _, err := d.getPodsOnNode(ctx, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names, nil
+}
+
+// getPodsOnNode gets all pods running on the specified node and sorts them
+// into pods to evict, pods to skip and pods that block the drain
+func (d *Drainer) getPodsOnNode(ctx context.Context, nodeName string) ([]corev1.Pod, []skippedPod, []skippedPod, error) {
 	fieldSelector := fields.OneTermEqualSelector("spec.nodeName", nodeName)
 
-	pods, err := d.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+	listOptions := metav1.ListOptions{
 		FieldSelector: fieldSelector.String(),
-		LabelSelector: d.config.PodSelector.String(),
-	})
+	}
+	if d.config.PodSelector != nil {
+		listOptions.LabelSelector = d.config.PodSelector.String()
+	}
+
+	pods, err := d.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, listOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods on node: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to list pods on node: %w", err)
 	}
 
 	// Filter out pods that should be ignored
-	var filteredPods []corev1.Pod
+	var (
+		filteredPods []corev1.Pod
+		skipped      []skippedPod
+		blocked      []skippedPod
+	)
 	for _, pod := range pods.Items {
-		if d.shouldEvictPod(&pod) {
+		result := d.shouldEvictPod(ctx, &pod)
+		switch result.Action {
+		case FilterInclude, FilterForceInclude:
 			filteredPods = append(filteredPods, pod)
+		case FilterSkip:
+			skipped = append(skipped, skippedPod{pod: pod, reason: result.Reason})
+		case FilterBlock:
+			blocked = append(blocked, skippedPod{pod: pod, reason: result.Reason})
 		}
 	}
 
-	return filteredPods, nil
+	return filteredPods, skipped, blocked, nil
 }
 
-// shouldEvictPod determines if a pod should be evicted
-func (d *Drainer) shouldEvictPod(pod *corev1.Pod) bool {
-	// Skip pods that are already terminating
-	if pod.DeletionTimestamp != nil {
-		return false
-	}
-
-	// Skip mirror pods
-	if pod.Annotations["kubernetes.io/config.mirror"] != "" {
-		return false
-	}
-
-	// Skip DaemonSet pods if configured to ignore them
-	if d.config.IgnoreDaemonSets {
-		if pod.OwnerReferences != nil {
-			for _, owner := range pod.OwnerReferences {
-				if owner.Kind == "DaemonSet" {
-					return false
-				}
-			}
+// shouldEvictPod runs a pod through the filter chain. The first filter that
+// does not simply include the pod decides the result, except that a block
+// gives way to a later filter that skips the pod: a pod the drain would
+// leave on the node anyway must not refuse the drain.
+func (d *Drainer) shouldEvictPod(ctx context.Context, pod *corev1.Pod) FilterResult {
+	var blocked *FilterResult
+	for _, filter := range d.filters {
+		result := filter.Filter(ctx, pod)
+		if result.Action == FilterInclude || (blocked != nil && result.Action != FilterSkip) {
+			continue
+		}
+		klog.FromContext(ctx).V(2).Info("Pod filtered", "pod", pod.Name, "namespace", pod.Namespace,
+			"filter", filter.Name(), "action", result.Action, "reason", result.Reason)
+		if result.Action == FilterBlock {
+			blocked = &result
+			continue
 		}
+		return result
 	}
-
-	// Skip pods with local storage unless force is enabled
-	if d.hasLocalStorage(pod) && !d.config.Force {
-		return false
+	if blocked != nil {
+		return *blocked
 	}
+	return include
+}
 
-	return true
+// recordSkippedPods emits an event on the node listing the pods left behind
+func (d *Drainer) recordSkippedPods(node *corev1.Node, skipped []skippedPod) {
+	if len(skipped) == 0 {
+		return
+	}
+	d.recorder.Eventf(node, corev1.EventTypeNormal, "PodsSkipped",
+		"Skipped %d pod(s) while draining node %s: %s", len(skipped), node.Name, strings.Join(podReasons(skipped), "; "))
 }
 
-// hasLocalStorage checks if a pod has local storage
-func (d *Drainer) hasLocalStorage(pod *corev1.Pod) bool {
-	for _, volume := range pod.Spec.Volumes {
-		if volume.EmptyDir != nil || volume.HostPath != nil {
-			return true
-		}
+// podReasons renders pods and their reasons as "namespace/name (reason)"
+func podReasons(pods []skippedPod) []string {
+	parts := make([]string, 0, len(pods))
+	for _, p := range pods {
+		parts = append(parts, fmt.Sprintf("%s/%s (%s)", p.pod.Namespace, p.pod.Name, p.reason))
 	}
-	return false
+	return parts
 }
 
-// evictPod evicts a single pod
-func (d *Drainer) evictPod(ctx context.Context, pod *corev1.Pod) error {
+// evictPod evicts a single pod. Evictions rejected with 429 TooManyRequests,
+// which is how the API server reports a PodDisruptionBudget violation, are
+// retried with exponential backoff until the context expires. Any other
+// error is returned immediately.
+func (d *Drainer) evictPod(ctx context.Context, pod *corev1.Pod, grace time.Duration) error {
 	log := klog.FromContext(ctx)
-	log.Info("Evicting pod", "pod", pod.Name, "namespace", pod.Namespace, "node", pod.Spec.NodeName)
+	log.Info("Evicting pod", "pod", pod.Name, "namespace", pod.Namespace, "node", pod.Spec.NodeName, "gracePeriod", grace)
 
 	// Create eviction object
 	eviction := &policyv1.Eviction{
@@ -202,40 +455,102 @@ func (d *Drainer) evictPod(ctx context.Context, pod *corev1.Pod) error {
 			Namespace: pod.Namespace,
 		},
 		DeleteOptions: &metav1.DeleteOptions{
-			GracePeriodSeconds: &[]int64{int64(d.config.GracePeriod.Seconds())}[0],
+			GracePeriodSeconds: gracePeriodSeconds(grace),
 		},
 	}
 
-	// Perform eviction
-	err := d.client.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
-	if err != nil {
+	delay := d.retryBackoff()
+	for attempt := 1; ; attempt++ {
+		// Perform eviction
+		err := d.client.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+		if err == nil {
+			break
+		}
 		if errors.IsNotFound(err) {
 			// Pod was already deleted
 			log.Info("Pod was already deleted", "pod", pod.Name, "namespace", pod.Namespace)
 			return nil
 		}
-		return fmt.Errorf("failed to evict pod: %w", err)
+		if !errors.IsTooManyRequests(err) {
+			return fmt.Errorf("failed to evict pod: %w", err)
+		}
+
+		// The eviction was blocked by a PodDisruptionBudget, back off and retry
+		if seconds, ok := errors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > delay {
+			delay = time.Duration(seconds) * time.Second
+		}
+		log.Info("Eviction blocked by PodDisruptionBudget, retrying", "pod", pod.Name, "namespace", pod.Namespace,
+			"attempt", attempt, "retryAfter", delay)
+		if attempt == 1 {
+			d.recorder.Eventf(pod, corev1.EventTypeWarning, "EvictionBlocked",
+				"Eviction of pod %s/%s is blocked by a PodDisruptionBudget, retrying: %v", pod.Namespace, pod.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("eviction still blocked by PodDisruptionBudget after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		delay = d.nextRetryBackoff(delay)
 	}
 
 	log.Info("Successfully evicted pod", "pod", pod.Name, "namespace", pod.Namespace)
 	return nil
 }
 
-// Uncordon marks a node as schedulable
-func (d *Drainer) Uncordon(ctx context.Context, node *corev1.Node) error {
+// retryBackoff returns the initial delay between blocked eviction retries
+func (d *Drainer) retryBackoff() time.Duration {
+	if d.config.RetryBackoff > 0 {
+		return d.config.RetryBackoff
+	}
+	return defaultRetryBackoff
+}
+
+// nextRetryBackoff grows the retry delay by the configured factor, capped at MaxRetryBackoff
+func (d *Drainer) nextRetryBackoff(delay time.Duration) time.Duration {
+	factor := d.config.RetryBackoffFactor
+	if factor < 1 {
+		factor = defaultRetryBackoffFactor
+	}
+	maxBackoff := d.config.MaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxRetryBackoff
+	}
+
+	next := time.Duration(float64(delay) * factor)
+	if next > maxBackoff {
+		return maxBackoff
+	}
+	return next
+}
+
+// Uncordon marks a node as schedulable, or removes draino2's taint in
+// CordonModeTaint. A node that draino2 did not cordon is left alone with a
+// CordonNotOwnedError unless force is set.
+func (d *Drainer) Uncordon(ctx context.Context, node *corev1.Node, force bool) error {
 	log := klog.FromContext(ctx)
 	log.Info("Uncordoning node", "node", node.Name)
 
 	// Check if node is already uncordoned
-	if !node.Spec.Unschedulable {
+	if !d.IsCordoned(node) {
 		log.Info("Node is already uncordoned", "node", node.Name)
+		return d.ForgetCordon(ctx, node)
+	}
+
+	if d.config.DryRun {
+		log.Info("Dry run: would uncordon node", "node", node.Name)
 		return nil
 	}
 
-	// Create a patch to mark the node as schedulable
-	patch := []byte(`{"spec":{"unschedulable":false}}`)
+	if !force && !d.ownsCordon(node) {
+		notOwnedErr := &CordonNotOwnedError{Node: node.Name}
+		log.Info("Refusing to uncordon node cordoned outside draino2", "node", node.Name)
+		d.recorder.Eventf(node, corev1.EventTypeWarning, "UncordonRefused", "%s", notOwnedErr.Error())
+		return notOwnedErr
+	}
 
-	_, err := d.client.CoreV1().Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	// In taint mode only draino2's own taints are removed
+	err := d.setCordoned(ctx, node.Name, false)
 	if err != nil {
 		log.Error(err, "Failed to uncordon node", "node", node.Name)
 		d.recorder.Eventf(node, corev1.EventTypeWarning, "UncordonFailed",
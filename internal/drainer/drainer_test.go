@@ -1,10 +1,38 @@
 package drainer
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 )
 
+// newTestPod returns a pod on node-1 that terminates immediately
+func newTestPod(name string) *corev1.Pod {
+	grace := int64(0)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: "uid-" + types.UID(name)},
+		Spec:       corev1.PodSpec{NodeName: "node-1", TerminationGracePeriodSeconds: &grace},
+	}
+}
+
 func TestDrainerConfig(t *testing.T) {
 	config := &DrainerConfig{
 		GracePeriod:        30 * time.Second,
@@ -48,3 +76,1363 @@ func TestDrainerConfig_DefaultValues(t *testing.T) {
 		t.Error("Timeout should not be negative")
 	}
 }
+
+func TestPodsNotTerminatedError(t *testing.T) {
+	err := &PodsNotTerminatedError{
+		Node: "node-1",
+		Pods: []string{"default/web-0", "kube-system/dns-1"},
+	}
+
+	expected := "timed out waiting for 2 pod(s) to terminate on node node-1: default/web-0, kube-system/dns-1"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestWaitForPodsDeleted_NoPods(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+
+	remaining, err := d.waitForPodsDeleted(context.Background(), nil)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("Expected no remaining pods, got %d", len(remaining))
+	}
+}
+
+func TestDrain_PodsNotTerminated(t *testing.T) {
+	podDeletionPollInterval = 10 * time.Millisecond
+
+	// The fake clientset accepts evictions without deleting the pod
+	client := fake.NewSimpleClientset(newTestPod("web-0"), newTestPod("web-1"))
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{
+		Timeout:               100 * time.Millisecond,
+		EvictUnreplicatedPods: true,
+	})
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	err := d.Drain(context.Background(), node)
+
+	var timeoutErr *PodsNotTerminatedError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected PodsNotTerminatedError, got %v", err)
+	}
+	if strings.Join(timeoutErr.Pods, ",") != "default/web-0,default/web-1" {
+		t.Errorf("Expected both pods to be reported, got %v", timeoutErr.Pods)
+	}
+}
+
+func TestDrain_CancelledWhileWaiting(t *testing.T) {
+	podDeletionPollInterval = 10 * time.Millisecond
+
+	client := fake.NewSimpleClientset(newTestPod("web-0"))
+	recorder := record.NewFakeRecorder(10)
+	d := NewDrainer(client, recorder, nil, &DrainerConfig{
+		Timeout:               time.Minute,
+		EvictUnreplicatedPods: true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	err := d.Drain(ctx, node)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	close(recorder.Events)
+	for event := range recorder.Events {
+		if strings.Contains(event, "DrainTimeout") {
+			t.Errorf("Expected no DrainTimeout event for a cancelled drain, got %q", event)
+		}
+	}
+}
+
+func TestWaitForPodsDeleted_Recreated(t *testing.T) {
+	podDeletionPollInterval = 10 * time.Millisecond
+
+	// A pod recreated with the same name has a new UID and counts as gone
+	recreated := newTestPod("web-0")
+	recreated.UID = "uid-new"
+	client := fake.NewSimpleClientset(recreated, newTestPod("web-1"))
+	d := NewDrainer(client, nil, nil, &DrainerConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	remaining, err := d.waitForPodsDeleted(ctx, []*evictedPod{
+		{pod: *newTestPod("web-0")}, {pod: *newTestPod("web-1")}, {pod: *newTestPod("web-2")},
+	})
+	if err == nil {
+		t.Fatal("Expected the wait to time out")
+	}
+	if len(remaining) != 1 || remaining[0].Name != "web-1" {
+		t.Errorf("Expected only web-1 to remain, got %v", podNames(remaining))
+	}
+}
+
+func TestNextRetryBackoff(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{
+		RetryBackoff:       2 * time.Second,
+		MaxRetryBackoff:    10 * time.Second,
+		RetryBackoffFactor: 3,
+	})
+
+	delay := d.retryBackoff()
+	if delay != 2*time.Second {
+		t.Errorf("Expected initial backoff of 2s, got %v", delay)
+	}
+
+	expected := []time.Duration{6 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, want := range expected {
+		delay = d.nextRetryBackoff(delay)
+		if delay != want {
+			t.Errorf("Step %d: expected %v, got %v", i, want, delay)
+		}
+	}
+}
+
+func TestEvictPod_RetriesTooManyRequests(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("web-0"))
+	attempts := 0
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		attempts++
+		if attempts < 3 {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		return false, nil, nil
+	})
+
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: 5 * time.Millisecond,
+	})
+
+	if err := d.evictPod(context.Background(), newTestPod("web-0"), 0); err != nil {
+		t.Fatalf("Expected eviction to succeed after retrying, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 eviction attempts, got %d", attempts)
+	}
+}
+
+func TestEvictPod_OtherErrorsNotRetried(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("web-0"))
+	attempts := 0
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		attempts++
+		return true, nil, apierrors.NewForbidden(corev1.Resource("pods"), "web-0", errors.New("denied"))
+	})
+
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{RetryBackoff: time.Millisecond})
+
+	if err := d.evictPod(context.Background(), newTestPod("web-0"), 0); err == nil {
+		t.Fatal("Expected eviction to fail")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single eviction attempt, got %d", attempts)
+	}
+}
+
+func TestNextRetryBackoff_Defaults(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+
+	if d.retryBackoff() != defaultRetryBackoff {
+		t.Errorf("Expected default backoff %v, got %v", defaultRetryBackoff, d.retryBackoff())
+	}
+
+	if next := d.nextRetryBackoff(defaultMaxRetryBackoff); next != defaultMaxRetryBackoff {
+		t.Errorf("Expected backoff to be capped at %v, got %v", defaultMaxRetryBackoff, next)
+	}
+}
+
+func TestMaxConcurrentEvictions(t *testing.T) {
+	tests := []struct {
+		configured int
+		expected   int
+	}{
+		{configured: 0, expected: 1},
+		{configured: -3, expected: 1},
+		{configured: 10, expected: 10},
+	}
+
+	for _, tt := range tests {
+		d := NewDrainer(nil, nil, nil, &DrainerConfig{MaxConcurrentEvictions: tt.configured})
+		if got := d.maxConcurrentEvictions(); got != tt.expected {
+			t.Errorf("MaxConcurrentEvictions=%d: expected %d workers, got %d", tt.configured, tt.expected, got)
+		}
+	}
+}
+
+// concurrencyClient records the highest number of evictions in flight at once.
+// Reactors on the fake clientset run under its lock, so the eviction is slowed
+// down outside of it.
+type concurrencyClient struct {
+	*fake.Clientset
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (c *concurrencyClient) CoreV1() corev1client.CoreV1Interface {
+	return concurrencyCoreV1{CoreV1Interface: c.Clientset.CoreV1(), client: c}
+}
+
+type concurrencyCoreV1 struct {
+	corev1client.CoreV1Interface
+	client *concurrencyClient
+}
+
+func (c concurrencyCoreV1) Pods(namespace string) corev1client.PodInterface {
+	return concurrencyPods{PodInterface: c.CoreV1Interface.Pods(namespace), client: c.client}
+}
+
+type concurrencyPods struct {
+	corev1client.PodInterface
+	client *concurrencyClient
+}
+
+func (p concurrencyPods) EvictV1(ctx context.Context, eviction *policyv1.Eviction) error {
+	n := atomic.AddInt32(&p.client.inFlight, 1)
+	defer atomic.AddInt32(&p.client.inFlight, -1)
+	for {
+		highest := atomic.LoadInt32(&p.client.maxInFlight)
+		if n <= highest || atomic.CompareAndSwapInt32(&p.client.maxInFlight, highest, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return p.PodInterface.EvictV1(ctx, eviction)
+}
+
+func TestEvictPods_ConcurrencyLimit(t *testing.T) {
+	var objects []runtime.Object
+	var pods []corev1.Pod
+	for i := 0; i < 6; i++ {
+		pod := newTestPod(fmt.Sprintf("web-%d", i))
+		objects = append(objects, pod)
+		pods = append(pods, *pod)
+	}
+	client := &concurrencyClient{Clientset: fake.NewSimpleClientset(objects...)}
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{MaxConcurrentEvictions: 2})
+
+	evicted, failures := d.evictPods(context.Background(), pods, time.Now())
+	if len(evicted) != 6 || len(failures) != 0 {
+		t.Fatalf("Expected 6 evictions and no failures, got %d and %d", len(evicted), len(failures))
+	}
+	if client.maxInFlight != 2 {
+		t.Errorf("Expected at most 2 evictions in flight, got %d", client.maxInFlight)
+	}
+}
+
+func TestEvictPods_Failures(t *testing.T) {
+	newClient := func(failing ...string) *fake.Clientset {
+		client := fake.NewSimpleClientset(newTestPod("web-0"), newTestPod("web-1"), newTestPod("web-2"), newTestPod("web-3"))
+		client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			name := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction).Name
+			for _, f := range failing {
+				if name == f {
+					return true, nil, apierrors.NewForbidden(corev1.Resource("pods"), name, errors.New("denied"))
+				}
+			}
+			return false, nil, nil
+		})
+		return client
+	}
+	pods := []corev1.Pod{*newTestPod("web-0"), *newTestPod("web-1"), *newTestPod("web-2"), *newTestPod("web-3")}
+
+	t.Run("first failure cancels the rest", func(t *testing.T) {
+		client := newClient("web-0")
+		d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{MaxConcurrentEvictions: 1})
+
+		evicted, failures := d.evictPods(context.Background(), pods, time.Now())
+		if len(evicted) != 0 {
+			t.Errorf("Expected no evictions, got %d", len(evicted))
+		}
+		if len(failures) != 4 || failures[0].pod.Name != "web-0" {
+			t.Fatalf("Expected web-0 to fail first followed by 3 unattempted pods, got %d failures", len(failures))
+		}
+		if attempts := evictionAttempts(client); attempts != 1 {
+			t.Errorf("Expected a single eviction attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("force collects every failure", func(t *testing.T) {
+		client := newClient("web-0", "web-2")
+		d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{MaxConcurrentEvictions: 1, Force: true})
+
+		evicted, failures := d.evictPods(context.Background(), pods, time.Now())
+		if len(evicted) != 2 {
+			t.Errorf("Expected 2 evictions, got %d", len(evicted))
+		}
+		if len(failures) != 2 || failures[0].pod.Name != "web-0" || failures[1].pod.Name != "web-2" {
+			t.Errorf("Expected web-0 and web-2 to fail, got %+v", failures)
+		}
+		if attempts := evictionAttempts(client); attempts != 4 {
+			t.Errorf("Expected 4 eviction attempts, got %d", attempts)
+		}
+	})
+}
+
+// evictionAttempts counts the evictions sent to the fake clientset
+func evictionAttempts(client *fake.Clientset) int {
+	attempts := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" && action.GetSubresource() == "eviction" {
+			attempts++
+		}
+	}
+	return attempts
+}
+
+func TestNextEscalation(t *testing.T) {
+	tests := []struct {
+		policy   EscalationPolicy
+		current  EscalationPolicy
+		expected EscalationPolicy
+	}{
+		{policy: "", current: "", expected: EscalationNone},
+		{policy: EscalationNone, current: "", expected: EscalationNone},
+		{policy: EscalationDelete, current: "", expected: EscalationDelete},
+		{policy: EscalationDelete, current: EscalationDelete, expected: EscalationNone},
+		{policy: EscalationForceDelete, current: "", expected: EscalationDelete},
+		{policy: EscalationForceDelete, current: EscalationDelete, expected: EscalationForceDelete},
+		{policy: EscalationForceDelete, current: EscalationForceDelete, expected: EscalationNone},
+	}
+
+	for _, tt := range tests {
+		d := NewDrainer(nil, nil, nil, &DrainerConfig{EscalationPolicy: tt.policy})
+		if got := d.nextEscalation(tt.current); got != tt.expected {
+			t.Errorf("policy=%q current=%q: expected %q, got %q", tt.policy, tt.current, tt.expected, got)
+		}
+	}
+}
+
+func TestEscalateIfDue_NotYetDue(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{
+		GracePeriod:      30 * time.Second,
+		EvictionHeadroom: time.Minute,
+		EscalationPolicy: EscalationForceDelete,
+	})
+
+	now := time.Now()
+	ep := &evictedPod{gracePeriod: 30 * time.Second, lastAction: now.Add(-time.Minute)}
+
+	// The client is nil, so this would panic if a delete were attempted
+	if err := d.escalateIfDue(context.Background(), ep, now); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if ep.escalation != "" {
+		t.Errorf("Expected no escalation before grace period plus headroom, got %q", ep.escalation)
+	}
+}
+
+func TestEscalateIfDue_Due(t *testing.T) {
+	pod := newTestPod("web-0")
+	client := fake.NewSimpleClientset(pod)
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{
+		EvictionHeadroom: time.Minute,
+		EscalationPolicy: EscalationForceDelete,
+	})
+
+	now := time.Now()
+	ep := &evictedPod{pod: *pod, gracePeriod: 30 * time.Second, lastAction: now.Add(-2 * time.Minute)}
+
+	if err := d.escalateIfDue(context.Background(), ep, now); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ep.escalation != EscalationDelete {
+		t.Fatalf("Expected escalation to delete, got %q", ep.escalation)
+	}
+
+	// The delete was accepted but the pod is still terminating
+	if err := client.Tracker().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.escalateIfDue(context.Background(), ep, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ep.escalation != EscalationForceDelete {
+		t.Fatalf("Expected escalation to force-delete, got %q", ep.escalation)
+	}
+
+	var graces []int64
+	for _, action := range client.Actions() {
+		if deleteAction, ok := action.(k8stesting.DeleteAction); ok {
+			graces = append(graces, *deleteAction.GetDeleteOptions().GracePeriodSeconds)
+		}
+	}
+	if len(graces) != 2 || graces[0] != 30 || graces[1] != 0 {
+		t.Errorf("Expected a delete with the pod's grace period then a force delete, got grace periods %v", graces)
+	}
+	if _, err := client.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected pod to be deleted, got %v", err)
+	}
+}
+
+func TestValidateEscalationPolicy(t *testing.T) {
+	for _, policy := range []EscalationPolicy{"", EscalationNone, EscalationDelete, EscalationForceDelete} {
+		if err := ValidateEscalationPolicy(policy); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", policy, err)
+		}
+	}
+	if err := ValidateEscalationPolicy("forcedelete"); err == nil {
+		t.Error("Expected unknown eviction fallback to be rejected")
+	}
+}
+
+func TestShouldEvictPod_Unreplicated(t *testing.T) {
+	bare := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "bare", Namespace: "default"},
+	}
+
+	tests := []struct {
+		name     string
+		config   DrainerConfig
+		expected FilterAction
+	}{
+		{name: "skipped by default", config: DrainerConfig{}, expected: FilterSkip},
+		{name: "blocks when configured", config: DrainerConfig{BlockOnUnreplicatedPods: true}, expected: FilterBlock},
+		{name: "evicted when allowed", config: DrainerConfig{EvictUnreplicatedPods: true}, expected: FilterInclude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDrainer(nil, nil, nil, &tt.config)
+			result := d.shouldEvictPod(context.Background(), bare)
+			if result.Action != tt.expected {
+				t.Errorf("Expected action %v, got %v (%s)", tt.expected, result.Action, result.Reason)
+			}
+		})
+	}
+}
+
+func TestDrainBlockedError(t *testing.T) {
+	err := &DrainBlockedError{
+		Node: "node-1",
+		Pods: podReasons([]skippedPod{{
+			pod:    corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "bare", Namespace: "default"}},
+			reason: "pod is not managed by a controller",
+		}}),
+	}
+
+	expected := "refusing to drain node node-1, blocked by 1 pod(s): default/bare (pod is not managed by a controller)"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLocalStorageReason(t *testing.T) {
+	podWith := func(volume corev1.VolumeSource) *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{Name: "data", VolumeSource: volume}},
+			},
+		}
+	}
+	emptyDir := podWith(corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}})
+	memoryEmptyDir := podWith(corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}})
+	hostPath := podWith(corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/data"}})
+
+	tests := []struct {
+		name     string
+		config   DrainerConfig
+		pod      *corev1.Pod
+		expected string
+	}{
+		{name: "emptyDir skipped", pod: emptyDir, expected: "pod uses emptyDir volume data"},
+		{name: "emptyDir allowed", config: DrainerConfig{DeleteEmptyDirData: true}, pod: emptyDir},
+		{name: "memory emptyDir skipped", config: DrainerConfig{DeleteEmptyDirData: true}, pod: memoryEmptyDir,
+			expected: "pod uses memory-backed emptyDir volume data"},
+		{name: "memory emptyDir allowed", config: DrainerConfig{DeleteMemoryEmptyDirData: true}, pod: memoryEmptyDir},
+		{name: "hostPath skipped", config: DrainerConfig{DeleteEmptyDirData: true}, pod: hostPath,
+			expected: "pod uses hostPath volume data"},
+		{name: "hostPath allowed", config: DrainerConfig{EvictHostPathPods: true}, pod: hostPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDrainer(nil, nil, nil, &tt.config)
+			reason, skip := d.localStorageReason(context.Background(), tt.pod)
+			if skip != (tt.expected != "") {
+				t.Fatalf("Expected skip=%v, got %v (%s)", tt.expected != "", skip, reason)
+			}
+			if reason != tt.expected {
+				t.Errorf("Expected reason %q, got %q", tt.expected, reason)
+			}
+		})
+	}
+}
+
+func TestPodFilterChain(t *testing.T) {
+	RegisterPodFilter("test-block-canary", func(d *Drainer) PodFilter {
+		return NewPodFilter("test-block-canary", func(_ context.Context, pod *corev1.Pod) FilterResult {
+			if pod.Labels["canary"] == "true" {
+				return FilterResult{Action: FilterBlock, Reason: "canary pods must be moved by hand"}
+			}
+			return FilterResult{Action: FilterInclude}
+		})
+	})
+
+	if err := ValidatePodFilters([]string{FilterMirror, "test-block-canary"}); err != nil {
+		t.Fatalf("Expected filters to be valid, got %v", err)
+	}
+	if err := ValidatePodFilters([]string{"does-not-exist"}); err == nil {
+		t.Error("Expected an error for an unknown filter")
+	}
+
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{
+		PodFilters: []string{FilterMirror, "test-block-canary"},
+	})
+
+	canary := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"canary": "true"}}}
+	if result := d.shouldEvictPod(context.Background(), canary); result.Action != FilterBlock {
+		t.Errorf("Expected canary pod to be blocked, got %v", result.Action)
+	}
+
+	mirrorCanary := canary.DeepCopy()
+	mirrorCanary.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "abc"}
+	if result := d.shouldEvictPod(context.Background(), mirrorCanary); result.Action != FilterSkip {
+		t.Errorf("Expected earlier mirror filter to skip the pod, got %v", result.Action)
+	}
+
+	// The unreplicated filter is not in the chain, so a bare pod is evicted
+	if result := d.shouldEvictPod(context.Background(), &corev1.Pod{}); result.Action != FilterInclude {
+		t.Errorf("Expected pod to be included, got %v", result.Action)
+	}
+}
+
+func TestFilterAnnotations(t *testing.T) {
+	tests := []struct {
+		annotations map[string]string
+		expected    FilterAction
+	}{
+		{annotations: nil, expected: FilterInclude},
+		{annotations: map[string]string{EvictAnnotation: EvictNever}, expected: FilterBlock},
+		{annotations: map[string]string{EvictAnnotation: EvictAlways}, expected: FilterForceInclude},
+		{annotations: map[string]string{EvictAnnotation: EvictLast}, expected: FilterInclude},
+		{annotations: map[string]string{SafeToEvictAnnotation: "false"}, expected: FilterBlock},
+		{annotations: map[string]string{SafeToEvictAnnotation: "true"}, expected: FilterForceInclude},
+		{annotations: map[string]string{EvictAnnotation: EvictAlways, SafeToEvictAnnotation: "false"}, expected: FilterForceInclude},
+		{annotations: map[string]string{EvictAnnotation: "sometimes"}, expected: FilterInclude},
+	}
+
+	for _, tt := range tests {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+		if result := filterAnnotations(context.Background(), pod); result.Action != tt.expected {
+			t.Errorf("Annotations %v: expected %v, got %v", tt.annotations, tt.expected, result.Action)
+		}
+	}
+}
+
+func TestShouldEvictPod_AlwaysEvictOverridesLaterFilters(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{EvictAnnotation: EvictAlways},
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+		},
+	}
+
+	if result := d.shouldEvictPod(context.Background(), pod); result.Action != FilterForceInclude {
+		t.Errorf("Expected pod to be force-included, got %v (%s)", result.Action, result.Reason)
+	}
+}
+
+func TestShouldEvictPod_NeverEvictSkippedDaemonSetPod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations:     map[string]string{SafeToEvictAnnotation: "false"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "node-exporter"}},
+		},
+	}
+
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{IgnoreDaemonSets: true})
+	if result := d.shouldEvictPod(context.Background(), pod); result.Action != FilterSkip {
+		t.Errorf("Expected ignored DaemonSet pod to be skipped, got %v (%s)", result.Action, result.Reason)
+	}
+
+	d = NewDrainer(nil, nil, nil, &DrainerConfig{IgnoreDaemonSets: false, EvictUnreplicatedPods: true})
+	if result := d.shouldEvictPod(context.Background(), pod); result.Action != FilterBlock {
+		t.Errorf("Expected DaemonSet pod to block the drain when DaemonSets are not ignored, got %v (%s)", result.Action, result.Reason)
+	}
+}
+
+func TestEvictionWaves_EvictLast(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "db", Annotations: map[string]string{EvictAnnotation: EvictLast}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+	}
+
+	waves := d.evictionWaves(pods)
+	if len(waves) != 2 {
+		t.Fatalf("Expected 2 waves, got %d", len(waves))
+	}
+	if waves[0].pods[0].Name != "web" || waves[1].pods[0].Name != "db" {
+		t.Errorf("Expected web before db, got %s then %s", waves[0].pods[0].Name, waves[1].pods[0].Name)
+	}
+
+	if waves := d.evictionWaves(pods[1:]); len(waves) != 1 {
+		t.Errorf("Expected empty waves to be dropped, got %d waves", len(waves))
+	}
+}
+
+func TestEvictionWaves_DefaultTiers(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+	controller := true
+
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "dns"}, Spec: corev1.PodSpec{PriorityClassName: "system-cluster-critical"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "report", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Job", Name: "report", Controller: &controller},
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "preemptible"}, Spec: corev1.PodSpec{Priority: &[]int32{-10}[0]}},
+	}
+
+	waves := d.evictionWaves(pods)
+
+	var order []string
+	for _, wave := range waves {
+		var names []string
+		for _, pod := range wave.pods {
+			names = append(names, pod.Name)
+		}
+		order = append(order, wave.name+":"+strings.Join(names, ","))
+	}
+
+	expected := []string{"batch:report,preemptible", "default:web", "critical:dns"}
+	if strings.Join(order, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected waves %v, got %v", expected, order)
+	}
+}
+
+func TestTierIndex_NoCatchAll(t *testing.T) {
+	tiers := []EvictionTier{{Name: "jobs", OwnerKinds: []string{"Job"}}}
+
+	if i := tierIndex(tiers, &corev1.Pod{}); i != -1 {
+		t.Errorf("Expected pod to match no tier, got %d", i)
+	}
+}
+
+func TestCapGracePeriod(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{GracePeriod: time.Minute})
+
+	tests := []struct {
+		grace    time.Duration
+		expected time.Duration
+	}{
+		{grace: 10 * time.Second, expected: 10 * time.Second},
+		{grace: time.Minute, expected: time.Minute},
+		{grace: time.Hour, expected: time.Minute},
+		{grace: -time.Second, expected: 0},
+	}
+
+	for _, tt := range tests {
+		if got := d.capGracePeriod(tt.grace); got != tt.expected {
+			t.Errorf("capGracePeriod(%v): expected %v, got %v", tt.grace, tt.expected, got)
+		}
+	}
+
+	unlimited := NewDrainer(nil, nil, nil, &DrainerConfig{})
+	if got := unlimited.capGracePeriod(time.Hour); got != time.Hour {
+		t.Errorf("Expected no cap without a maximum, got %v", got)
+	}
+}
+
+func TestParseGracePeriod(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{value: "45", expected: 45 * time.Second},
+		{value: "90s", expected: 90 * time.Second},
+		{value: "5m", expected: 5 * time.Minute},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGracePeriod(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGracePeriod(%q): unexpected error %v", tt.value, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("parseGracePeriod(%q): expected %v, got %v", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestEffectiveGracePeriod(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "annotated",
+			Annotations: map[string]string{GracePeriodAnnotation: "90s"},
+		}},
+	)
+	d := NewDrainer(client, nil, nil, &DrainerConfig{GracePeriod: 2 * time.Minute})
+
+	seconds := func(s int64) *int64 { return &s }
+
+	tests := []struct {
+		name        string
+		namespace   string
+		grace       *int64
+		annotations map[string]string
+		expected    time.Duration
+	}{
+		{name: "unset defaults to 30s", namespace: "plain", expected: 30 * time.Second},
+		{name: "pod grace period below the maximum", namespace: "plain", grace: seconds(60), expected: time.Minute},
+		{name: "pod grace period above the maximum", namespace: "plain", grace: seconds(300), expected: 2 * time.Minute},
+		{name: "namespace annotation", namespace: "annotated", grace: seconds(10), expected: 90 * time.Second},
+		{
+			name:        "pod annotation wins over namespace",
+			namespace:   "annotated",
+			annotations: map[string]string{GracePeriodAnnotation: "45s"},
+			expected:    45 * time.Second,
+		},
+		{
+			name:        "override is clamped to the maximum",
+			namespace:   "plain",
+			annotations: map[string]string{GracePeriodAnnotation: "10m"},
+			expected:    2 * time.Minute,
+		},
+		{
+			name:        "invalid pod annotation falls back to namespace",
+			namespace:   "annotated",
+			annotations: map[string]string{GracePeriodAnnotation: "soon"},
+			expected:    90 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: tt.namespace, Annotations: tt.annotations},
+				Spec:       corev1.PodSpec{TerminationGracePeriodSeconds: tt.grace},
+			}
+			if got := d.effectiveGracePeriod(context.Background(), pod); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDrainPlanSummary(t *testing.T) {
+	plan := &DrainPlan{
+		Node:        "node-1",
+		WouldCordon: true,
+		Waves: []PlannedWave{
+			{Tier: "default", Pods: []PlannedPod{{Namespace: "default", Name: "web-0"}, {Namespace: "default", Name: "web-1"}}},
+			{Tier: "critical", Pods: []PlannedPod{{Namespace: "kube-system", Name: "dns-0"}}},
+		},
+		Skipped:      []PlannedPod{{Namespace: "kube-system", Name: "proxy", Reason: "pod is managed by a DaemonSet"}},
+		BlockingPDBs: []BlockingPDB{{Namespace: "default", Name: "web", Pods: []string{"default/web-0", "default/web-1"}}},
+	}
+
+	if plan.Evictions() != 3 {
+		t.Errorf("Expected 3 evictions, got %d", plan.Evictions())
+	}
+
+	expected := "would cordon: true, would evict 3 pod(s) in 2 wave(s), skip 1 pod(s), blocked by 0 pod(s) and 1 PodDisruptionBudget(s)"
+	if plan.Summary() != expected {
+		t.Errorf("Expected %q, got %q", expected, plan.Summary())
+	}
+}
+
+// newPlanTestClient returns a clientset with pods on node-1 covering every
+// part of a drain plan, and PodDisruptionBudgets of which only "web" blocks
+func newPlanTestClient() *fake.Clientset {
+	web0, web1, dns := newTestPod("web-0"), newTestPod("web-1"), newTestPod("dns-0")
+	web0.Labels = map[string]string{"app": "web"}
+	web1.Labels = map[string]string{"app": "web"}
+	dns.Labels = map[string]string{"app": "dns"}
+	dns.Spec.PriorityClassName = "system-cluster-critical"
+
+	proxy := newTestPod("proxy")
+	proxy.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "proxy"}}
+
+	pinned := newTestPod("pinned")
+	pinned.Annotations = map[string]string{EvictAnnotation: EvictNever}
+
+	pdb := func(name, app string, allowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			},
+			Status: policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+	}
+
+	return fake.NewSimpleClientset(web0, web1, dns, proxy, pinned, pdb("web", "web", 1), pdb("dns", "dns", 1))
+}
+
+func TestPlan(t *testing.T) {
+	client := newPlanTestClient()
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{
+		DryRun:                true,
+		IgnoreDaemonSets:      true,
+		EvictUnreplicatedPods: true,
+	})
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	plan, err := d.Plan(context.Background(), node, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !plan.WouldCordon {
+		t.Error("Expected the plan to cordon the node")
+	}
+
+	var waves []string
+	for _, wave := range plan.Waves {
+		var names []string
+		for _, pod := range wave.Pods {
+			names = append(names, pod.Name)
+		}
+		waves = append(waves, wave.Tier+":"+strings.Join(names, ","))
+	}
+	if expected := "default:web-0,web-1 critical:dns-0"; strings.Join(waves, " ") != expected {
+		t.Errorf("Expected waves %q, got %q", expected, strings.Join(waves, " "))
+	}
+
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Name != "proxy" {
+		t.Errorf("Expected proxy to be skipped, got %+v", plan.Skipped)
+	}
+	if len(plan.Blocked) != 1 || plan.Blocked[0].Name != "pinned" {
+		t.Errorf("Expected pinned to block the drain, got %+v", plan.Blocked)
+	}
+
+	if len(plan.BlockingPDBs) != 1 {
+		t.Fatalf("Expected 1 blocking PodDisruptionBudget, got %+v", plan.BlockingPDBs)
+	}
+	blocking := plan.BlockingPDBs[0]
+	if blocking.Name != "web" || blocking.DisruptionsAllowed != 1 || len(blocking.Pods) != 2 {
+		t.Errorf("Expected PodDisruptionBudget web to block 2 pods, got %+v", blocking)
+	}
+
+	if last, ok := d.LastPlan("node-1"); !ok || last != plan {
+		t.Error("Expected the plan to be kept as the node's last plan")
+	}
+}
+
+func TestDrain_DryRunDoesNotEvict(t *testing.T) {
+	client := newPlanTestClient()
+	recorder := record.NewFakeRecorder(10)
+	d := NewDrainer(client, recorder, nil, &DrainerConfig{
+		DryRun:                true,
+		IgnoreDaemonSets:      true,
+		EvictUnreplicatedPods: true,
+	})
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	if err := d.Drain(context.Background(), node); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" && action.GetVerb() != "list" {
+			t.Errorf("Expected only reads in dry-run, got %s %s/%s", action.GetVerb(), action.GetResource().Resource, action.GetSubresource())
+		}
+	}
+	if attempts := evictionAttempts(client); attempts != 0 {
+		t.Errorf("Expected no evictions in dry-run, got %d", attempts)
+	}
+	if _, ok := d.LastPlan("node-1"); !ok {
+		t.Error("Expected the dry-run drain to record a plan")
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "DrainPlanned") {
+		t.Errorf("Expected a DrainPlanned event, got %q", event)
+	}
+}
+
+func TestDryRunCordonDoesNotPatch(t *testing.T) {
+	// The client is nil, so this would panic if a patch were sent
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{DryRun: true})
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	if err := d.Cordon(context.Background(), node); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	node.Spec.Unschedulable = true
+	if err := d.Uncordon(context.Background(), node, false); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestSimulatedNodeFits(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{
+			"pool":                        "general",
+			"topology.kubernetes.io/zone": "a",
+		}},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{{Key: "dedicated", Value: "general", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+				corev1.ResourcePods:   resource.MustParse("10"),
+			},
+		},
+	}
+	toleration := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "general", Effect: corev1.TaintEffectNoSchedule}
+
+	newPod := func(cpu string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: map[string]string{"app": "db"}},
+			Spec: corev1.PodSpec{
+				Tolerations: []corev1.Toleration{toleration},
+				Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				}}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(pod *corev1.Pod)
+		cpu      string
+		expected string
+	}{
+		{name: "fits", cpu: "1"},
+		{name: "too much cpu", cpu: "3", expected: "insufficient cpu"},
+		{name: "untolerated taint", cpu: "1", expected: "untolerated taint",
+			modify: func(pod *corev1.Pod) { pod.Spec.Tolerations = nil }},
+		{name: "node selector", cpu: "1", expected: "node selector mismatch",
+			modify: func(pod *corev1.Pod) { pod.Spec.NodeSelector = map[string]string{"pool": "gpu"} }},
+		{name: "node affinity", cpu: "1", expected: "node affinity mismatch",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: "pool", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"general"}},
+						}}},
+					},
+				}}
+			}},
+		{name: "anti-affinity", cpu: "1", expected: "pod anti-affinity",
+			modify: func(pod *corev1.Pod) {
+				pod.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+						TopologyKey:   "topology.kubernetes.io/zone",
+					}},
+				}}
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate := &simulatedNode{node: node, free: node.Status.Allocatable.DeepCopy()}
+			candidate.place(newPod("500m"), podRequests(newPod("500m")))
+
+			pod := newPod(tt.cpu)
+			if tt.modify != nil {
+				tt.modify(pod)
+			}
+
+			reason := candidate.fits(pod, podRequests(pod), []*simulatedNode{candidate})
+			if reason != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, reason)
+			}
+		})
+	}
+}
+
+func TestPodRequests(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			}}},
+			Containers: []corev1.Container{
+				{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}}},
+				{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}},
+			},
+		},
+	}
+
+	requests := podRequests(pod)
+	if requests.Cpu().Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("Expected init container cpu request to dominate, got %s", requests.Cpu())
+	}
+	if requests.Memory().Cmp(resource.MustParse("1Gi")) != 0 {
+		t.Errorf("Expected 1Gi memory, got %s", requests.Memory())
+	}
+	if requests.Pods().Value() != 1 {
+		t.Errorf("Expected one pod slot, got %d", requests.Pods().Value())
+	}
+}
+
+func TestEvictionGroups(t *testing.T) {
+	controller := true
+	owned := func(name, kind, owner string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{Kind: kind, Name: owner, Controller: &controller},
+		}}}
+	}
+	pods := []corev1.Pod{
+		owned("etcd-0", "StatefulSet", "etcd"),
+		owned("web-abc", "ReplicaSet", "web"),
+		owned("etcd-1", "StatefulSet", "etcd"),
+		owned("web-def", "ReplicaSet", "web"),
+		owned("kafka-0", "StatefulSet", "kafka"),
+	}
+
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+	groups := d.evictionGroups(pods)
+	if len(groups) != 4 {
+		t.Fatalf("Expected 4 groups, got %d", len(groups))
+	}
+	if groups[0].ownerName != "etcd" || len(groups[0].pods) != 2 || groups[0].pods[1].Name != "etcd-1" {
+		t.Errorf("Expected etcd pods to form one serial group, got %+v", groups[0])
+	}
+	if groups[1].serial() || len(groups[1].pods) != 1 {
+		t.Errorf("Expected ReplicaSet pod to form its own group, got %+v", groups[1])
+	}
+	if groups[3].ownerName != "kafka" {
+		t.Errorf("Expected kafka group last, got %+v", groups[3])
+	}
+}
+
+func TestIsPodReady(t *testing.T) {
+	pod := &corev1.Pod{}
+	if isPodReady(pod) {
+		t.Error("Expected pod without conditions to be not ready")
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	if !isPodReady(pod) {
+		t.Error("Expected pod to be ready")
+	}
+}
+
+func TestWaitForReplacement(t *testing.T) {
+	podDeletionPollInterval = 10 * time.Millisecond
+	controller := true
+
+	statefulSetPod := func(uid types.UID, ready bool) *corev1.Pod {
+		pod := newTestPod("etcd-0")
+		pod.UID = uid
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "etcd", Controller: &controller}}
+		if ready {
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		return pod
+	}
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "default"},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 3},
+	}
+	group := evictionGroup{ownerKind: "StatefulSet", ownerName: "etcd"}
+	evicted := &evictedPod{pod: *statefulSetPod("uid-old", true)}
+
+	t.Run("replacement ready", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSetPod("uid-new", true), statefulSet)
+		d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{StatefulSetReadyTimeout: time.Second})
+
+		if err := d.waitForReplacement(context.Background(), group, evicted, 3, time.Now()); err != nil {
+			t.Errorf("Expected replacement to be found, got %v", err)
+		}
+	})
+
+	t.Run("replacement not ready", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSetPod("uid-new", false), statefulSet)
+		d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{StatefulSetReadyTimeout: 50 * time.Millisecond})
+
+		if err := d.waitForReplacement(context.Background(), group, evicted, 3, time.Now()); err == nil {
+			t.Error("Expected an error for a replacement that never becomes Ready")
+		}
+	})
+
+	t.Run("timeout covers the whole drain", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSetPod("uid-new", false), statefulSet)
+		d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{StatefulSetReadyTimeout: time.Minute})
+
+		// The drain started a minute ago, so its budget is already spent
+		began := time.Now()
+		if err := d.waitForReplacement(context.Background(), group, evicted, 3, began.Add(-time.Minute)); err == nil {
+			t.Error("Expected an error once the drain's StatefulSet timeout has expired")
+		}
+		if elapsed := time.Since(began); elapsed > time.Second {
+			t.Errorf("Expected the wait to give up immediately, took %v", elapsed)
+		}
+	})
+
+	t.Run("ready replicas not recovered", func(t *testing.T) {
+		client := fake.NewSimpleClientset(statefulSetPod("uid-new", true), statefulSet)
+		d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{StatefulSetReadyTimeout: 50 * time.Millisecond})
+
+		if err := d.waitForReplacement(context.Background(), group, evicted, 4, time.Now()); err == nil {
+			t.Error("Expected an error while the StatefulSet has fewer ready replicas than before")
+		}
+	})
+}
+
+func TestEvictionGroups_Rolling(t *testing.T) {
+	controller := true
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "ReplicaSet", Name: "web", Controller: &controller},
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-def", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+			{Kind: "ReplicaSet", Name: "web", Controller: &controller},
+		}}},
+	}
+
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{RollingEviction: true})
+	groups := d.evictionGroups(pods)
+	if len(groups) != 1 || groups[0].ownerKind != "ReplicaSet" || len(groups[0].pods) != 2 {
+		t.Errorf("Expected ReplicaSet pods to form one serial group in rolling mode, got %+v", groups)
+	}
+
+	d.config.RollingEviction = false
+	if groups := d.evictionGroups(pods); len(groups) != 2 {
+		t.Errorf("Expected ReplicaSet pods to be evicted independently, got %d groups", len(groups))
+	}
+}
+
+func TestWaitForReplacement_Rolling(t *testing.T) {
+	podDeletionPollInterval = 10 * time.Millisecond
+
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Status:     appsv1.ReplicaSetStatus{AvailableReplicas: 2},
+	}
+	group := evictionGroup{ownerKind: "ReplicaSet", ownerName: "web"}
+	evicted := &evictedPod{pod: *newTestPod("web-abc")}
+
+	client := fake.NewSimpleClientset(replicaSet)
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{RollingEvictionTimeout: 50 * time.Millisecond})
+
+	if err := d.waitForReplacement(context.Background(), group, evicted, 3, time.Now()); err == nil {
+		t.Error("Expected an error while the ReplicaSet has fewer available replicas than before")
+	}
+	if err := d.waitForReplacement(context.Background(), group, evicted, 2, time.Now()); err != nil {
+		t.Errorf("Expected the ReplicaSet to have recovered, got %v", err)
+	}
+}
+
+func TestAttachedVolumes(t *testing.T) {
+	pv := "pv-data"
+	daemonPV := "pv-daemon"
+	attachments := []storagev1.VolumeAttachment{
+		{ObjectMeta: metav1.ObjectMeta{Name: "csi-1"}, Spec: storagev1.VolumeAttachmentSpec{
+			NodeName: "node-1", Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pv}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "csi-2"}, Spec: storagev1.VolumeAttachmentSpec{NodeName: "node-1", Attacher: "ebs.csi.aws.com"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "csi-3"}, Spec: storagev1.VolumeAttachmentSpec{NodeName: "node-2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "csi-4"}, Spec: storagev1.VolumeAttachmentSpec{
+			NodeName: "node-1", Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &daemonPV}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "csi-5"}, Spec: storagev1.VolumeAttachmentSpec{NodeName: "node-1", Attacher: "secrets-store.csi.k8s.io"}},
+	}
+	inUse := volumeUsage{
+		persistentVolumes: map[string]bool{"pv-daemon": true},
+		inlineDrivers:     map[string]bool{"secrets-store.csi.k8s.io": true},
+	}
+
+	volumes := attachedVolumes(attachments, "node-1", inUse)
+	if len(volumes) != 2 || volumes[0] != "pv-data" || volumes[1] != "csi-2" {
+		t.Errorf("Expected [pv-data csi-2], got %v", volumes)
+	}
+}
+
+func TestWaitForVolumeDetach_IgnoresVolumesInUse(t *testing.T) {
+	volumeDetachPollInterval = 10 * time.Millisecond
+
+	attachment := func(name, pv string) *storagev1.VolumeAttachment {
+		return &storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: storagev1.VolumeAttachmentSpec{
+				NodeName: "node-1",
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pv},
+			},
+		}
+	}
+	claim := func(name, pv string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: pv},
+		}
+	}
+
+	// A DaemonSet pod left on the node keeps its volume attached
+	daemon := newTestPod("logs-abc")
+	daemon.Spec.Volumes = []corev1.Volume{{Name: "buffer", VolumeSource: corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "logs-buffer"},
+	}}}
+
+	client := fake.NewSimpleClientset(
+		daemon,
+		claim("logs-buffer", "pv-logs"),
+		attachment("csi-logs", "pv-logs"),
+		attachment("csi-web", "pv-web"),
+	)
+	d := NewDrainer(client, record.NewFakeRecorder(10), nil, &DrainerConfig{VolumeDetachTimeout: 50 * time.Millisecond})
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+	err := d.WaitForVolumeDetach(context.Background(), node)
+	var attachedErr *VolumesAttachedError
+	if !errors.As(err, &attachedErr) {
+		t.Fatalf("Expected VolumesAttachedError, got %v", err)
+	}
+	if len(attachedErr.Volumes) != 1 || attachedErr.Volumes[0] != "pv-web" {
+		t.Errorf("Expected only pv-web to be reported, got %v", attachedErr.Volumes)
+	}
+
+	// Once the evicted pod's volume detaches the wait completes
+	if err := client.StorageV1().VolumeAttachments().Delete(context.Background(), "csi-web", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WaitForVolumeDetach(context.Background(), node); err != nil {
+		t.Errorf("Expected the volume in use by the DaemonSet pod to be ignored, got %v", err)
+	}
+}
+
+func TestIsCordoned_TaintMode(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{CordonMode: CordonModeTaint, CordonNoExecute: true})
+
+	node := &corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}}
+	if d.IsCordoned(node) {
+		t.Error("Expected unschedulable node without the taint to be uncordoned in taint mode")
+	}
+
+	node.Spec.Taints = []corev1.Taint{{Key: DefaultCordonTaintKey, Effect: corev1.TaintEffectNoSchedule}}
+	if d.IsCordoned(node) {
+		t.Error("Expected node missing the NoExecute taint to be uncordoned")
+	}
+
+	node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: DefaultCordonTaintKey, Effect: corev1.TaintEffectNoExecute})
+	if !d.IsCordoned(node) {
+		t.Error("Expected node with both taints to be cordoned")
+	}
+}
+
+func TestValidateCordonMode(t *testing.T) {
+	for _, mode := range []CordonMode{"", CordonModeUnschedulable, CordonModeTaint} {
+		if err := ValidateCordonMode(mode); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", mode, err)
+		}
+	}
+	if err := ValidateCordonMode("drain"); err == nil {
+		t.Error("Expected unknown cordon mode to be rejected")
+	}
+}
+
+func TestUncordonRefusesManualCordon(t *testing.T) {
+	// The client is nil, so this would panic if a patch were sent
+	d := NewDrainer(nil, record.NewFakeRecorder(10), nil, &DrainerConfig{})
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: corev1.NodeSpec{Unschedulable: true}}
+	err := d.Uncordon(context.Background(), node, false)
+
+	var notOwnedErr *CordonNotOwnedError
+	if !errors.As(err, &notOwnedErr) {
+		t.Fatalf("Expected CordonNotOwnedError, got %v", err)
+	}
+	if notOwnedErr.Node != "node-1" {
+		t.Errorf("Expected node-1, got %s", notOwnedErr.Node)
+	}
+}
+
+func TestOwnsCordon(t *testing.T) {
+	d := NewDrainer(nil, nil, nil, &DrainerConfig{})
+
+	node := &corev1.Node{}
+	if d.ownsCordon(node) {
+		t.Error("Expected node without annotation not to be owned")
+	}
+
+	node.Annotations = map[string]string{CordonedByAnnotation: "true"}
+	if !d.ownsCordon(node) {
+		t.Error("Expected annotated node to be owned")
+	}
+
+	d.config.CordonMode = CordonModeTaint
+	if !d.ownsCordon(&corev1.Node{}) {
+		t.Error("Expected draino2's taint to always be owned in taint mode")
+	}
+}
+
+func TestCordon_ManualCordonAfterUncordon(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	d := NewDrainer(clientset, record.NewFakeRecorder(10), nil, &DrainerConfig{})
+
+	getNode := func() *corev1.Node {
+		node, err := clientset.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get node: %v", err)
+		}
+		return node
+	}
+	setUnschedulable := func(unschedulable bool) {
+		node := getNode()
+		node.Spec.Unschedulable = unschedulable
+		if _, err := clientset.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("Failed to update node: %v", err)
+		}
+	}
+
+	if err := d.Cordon(ctx, getNode()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !d.ownsCordon(getNode()) {
+		t.Fatal("Expected draino2 to own its cordon")
+	}
+
+	// kubectl uncordon leaves the annotation behind until draino2 sees the node
+	setUnschedulable(false)
+	if err := d.ForgetCordon(ctx, getNode()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// kubectl cordon
+	setUnschedulable(true)
+	if err := d.Cordon(ctx, getNode()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var notOwnedErr *CordonNotOwnedError
+	if err := d.Uncordon(ctx, getNode(), false); !errors.As(err, &notOwnedErr) {
+		t.Fatalf("Expected CordonNotOwnedError, got %v", err)
+	}
+	if !getNode().Spec.Unschedulable {
+		t.Error("Expected the manual cordon to be left in place")
+	}
+}
+
+func TestUncordon_ForgetsCordonOfUncordonedNode(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node-1",
+		Annotations: map[string]string{CordonedByAnnotation: "true"},
+	}}
+	clientset := fake.NewSimpleClientset(node)
+	d := NewDrainer(clientset, record.NewFakeRecorder(10), nil, &DrainerConfig{})
+
+	if err := d.Uncordon(context.Background(), node, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	updated, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get node: %v", err)
+	}
+	if _, ok := updated.Annotations[CordonedByAnnotation]; ok {
+		t.Errorf("Expected %s to be removed from the uncordoned node", CordonedByAnnotation)
+	}
+}
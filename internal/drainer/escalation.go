@@ -0,0 +1,108 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// EscalationPolicy controls what happens to evicted pods that are still
+// present after their grace period plus the eviction headroom
+type EscalationPolicy string
+
+const (
+	// EscalationNone keeps waiting for the eviction to complete
+	EscalationNone EscalationPolicy = "none"
+	// EscalationDelete deletes the pod directly, honoring its grace period
+	EscalationDelete EscalationPolicy = "delete"
+	// EscalationForceDelete deletes the pod directly and, if that also does
+	// not complete in time, deletes it again with a zero grace period
+	EscalationForceDelete EscalationPolicy = "force-delete"
+)
+
+// ValidateEscalationPolicy checks that policy is empty or a known EscalationPolicy
+func ValidateEscalationPolicy(policy EscalationPolicy) error {
+	switch policy {
+	case "", EscalationNone, EscalationDelete, EscalationForceDelete:
+		return nil
+	}
+	return fmt.Errorf("unknown eviction fallback %q", policy)
+}
+
+// evictedPod tracks a pod from the moment its eviction was accepted
+type evictedPod struct {
+	pod corev1.Pod
+	// gracePeriod is the grace period the pod was evicted with
+	gracePeriod time.Duration
+	// lastAction is when the pod was evicted or last escalated
+	lastAction time.Time
+	// escalation is the most severe escalation applied so far
+	escalation EscalationPolicy
+}
+
+// escalateIfDue escalates an evicted pod that is still present once its grace
+// period plus the eviction headroom has elapsed since the last action taken on it
+func (d *Drainer) escalateIfDue(ctx context.Context, ep *evictedPod, now time.Time) error {
+	next := d.nextEscalation(ep.escalation)
+	if next == EscalationNone {
+		return nil
+	}
+	if now.Before(ep.lastAction.Add(ep.gracePeriod + d.config.EvictionHeadroom)) {
+		return nil
+	}
+
+	log := klog.FromContext(ctx)
+	pod := &ep.pod
+
+	opts := metav1.DeleteOptions{
+		Preconditions: metav1.NewUIDPreconditions(string(pod.UID)),
+	}
+	reason := "EvictionEscalatedToDelete"
+	if next == EscalationForceDelete {
+		opts.GracePeriodSeconds = gracePeriodSeconds(0)
+		reason = "EvictionEscalatedToForceDelete"
+	} else {
+		opts.GracePeriodSeconds = gracePeriodSeconds(ep.gracePeriod)
+	}
+
+	log.Info("Eviction did not complete in time, escalating", "pod", pod.Name, "namespace", pod.Namespace, "escalation", next)
+	err := d.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, opts)
+	if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+		return fmt.Errorf("failed to delete pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	ep.escalation = next
+	ep.lastAction = now
+
+	d.recorder.Eventf(pod, corev1.EventTypeWarning, reason,
+		"Pod %s/%s was not terminated within %s of eviction from node %s, escalated to %s",
+		pod.Namespace, pod.Name, ep.gracePeriod+d.config.EvictionHeadroom, pod.Spec.NodeName, next)
+	if d.metrics != nil {
+		d.metrics.PodEvictionEscalations.WithLabelValues(string(next)).Inc()
+	}
+
+	return nil
+}
+
+// nextEscalation returns the escalation that follows current under the configured policy
+func (d *Drainer) nextEscalation(current EscalationPolicy) EscalationPolicy {
+	switch d.config.EscalationPolicy {
+	case EscalationDelete:
+		if current == "" {
+			return EscalationDelete
+		}
+	case EscalationForceDelete:
+		switch current {
+		case "":
+			return EscalationDelete
+		case EscalationDelete:
+			return EscalationForceDelete
+		}
+	}
+	return EscalationNone
+}
@@ -0,0 +1,220 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FilterAction is the decision a PodFilter makes about a pod
+type FilterAction int
+
+const (
+	// FilterInclude means the filter has no objection to evicting the pod
+	FilterInclude FilterAction = iota
+	// FilterSkip means the pod should be left on the node
+	FilterSkip
+	// FilterBlock means the pod prevents the node from being drained
+	FilterBlock
+	// FilterForceInclude means the pod is evicted without consulting the
+	// remaining filters
+	FilterForceInclude
+)
+
+// String returns a human readable name for the action
+func (a FilterAction) String() string {
+	switch a {
+	case FilterInclude:
+		return "include"
+	case FilterSkip:
+		return "skip"
+	case FilterBlock:
+		return "block"
+	case FilterForceInclude:
+		return "force-include"
+	default:
+		return fmt.Sprintf("FilterAction(%d)", int(a))
+	}
+}
+
+// FilterResult is the outcome of running a PodFilter
+type FilterResult struct {
+	Action FilterAction
+	Reason string
+}
+
+// PodFilter decides whether a pod is eligible for eviction. Filters are
+// evaluated in order and the first one that skips, blocks or force-includes
+// a pod decides its fate, except that a blocked pod is still skipped if a
+// later filter skips it; otherwise a pod is evicted only if every filter
+// includes it.
+type PodFilter interface {
+	// Name identifies the filter in configuration and logs
+	Name() string
+	// Filter inspects a pod and returns the filter's decision
+	Filter(ctx context.Context, pod *corev1.Pod) FilterResult
+}
+
+// PodFilterFactory builds a PodFilter for a drainer, so that filters can
+// read the drainer's configuration
+type PodFilterFactory func(d *Drainer) PodFilter
+
+// Built-in filter names
+const (
+	FilterTerminating  = "terminating"
+	FilterMirror       = "mirror"
+	FilterAnnotations  = "annotations"
+	FilterDaemonSet    = "daemonset"
+	FilterLocalStorage = "local-storage"
+	FilterUnreplicated = "unreplicated"
+)
+
+// DefaultPodFilters is the filter chain used when none is configured
+var DefaultPodFilters = []string{
+	FilterTerminating,
+	FilterMirror,
+	FilterAnnotations,
+	FilterDaemonSet,
+	FilterLocalStorage,
+	FilterUnreplicated,
+}
+
+var (
+	podFiltersLock sync.RWMutex
+	podFilters     = map[string]PodFilterFactory{
+		FilterTerminating:  func(d *Drainer) PodFilter { return NewPodFilter(FilterTerminating, filterTerminating) },
+		FilterMirror:       func(d *Drainer) PodFilter { return NewPodFilter(FilterMirror, filterMirror) },
+		FilterAnnotations:  func(d *Drainer) PodFilter { return NewPodFilter(FilterAnnotations, filterAnnotations) },
+		FilterDaemonSet:    func(d *Drainer) PodFilter { return NewPodFilter(FilterDaemonSet, d.filterDaemonSet) },
+		FilterLocalStorage: func(d *Drainer) PodFilter { return NewPodFilter(FilterLocalStorage, d.filterLocalStorage) },
+		FilterUnreplicated: func(d *Drainer) PodFilter { return NewPodFilter(FilterUnreplicated, d.filterUnreplicated) },
+	}
+)
+
+// RegisterPodFilter makes a filter available by name to DrainerConfig.PodFilters.
+// Registering a name that already exists replaces the previous filter.
+func RegisterPodFilter(name string, factory PodFilterFactory) {
+	podFiltersLock.Lock()
+	defer podFiltersLock.Unlock()
+	podFilters[name] = factory
+}
+
+// ValidatePodFilters checks that every named filter has been registered
+func ValidatePodFilters(names []string) error {
+	podFiltersLock.RLock()
+	defer podFiltersLock.RUnlock()
+
+	for _, name := range names {
+		if _, ok := podFilters[name]; !ok {
+			return fmt.Errorf("unknown pod filter %q", name)
+		}
+	}
+	return nil
+}
+
+// buildPodFilters assembles the filter chain for a drainer. Unknown names
+// are rejected by ValidatePodFilters before the drainer is created.
+func buildPodFilters(d *Drainer, names []string) []PodFilter {
+	if len(names) == 0 {
+		names = DefaultPodFilters
+	}
+
+	podFiltersLock.RLock()
+	defer podFiltersLock.RUnlock()
+
+	chain := make([]PodFilter, 0, len(names))
+	for _, name := range names {
+		if factory, ok := podFilters[name]; ok {
+			chain = append(chain, factory(d))
+		}
+	}
+	return chain
+}
+
+// funcFilter adapts a function to the PodFilter interface
+type funcFilter struct {
+	name string
+	fn   func(ctx context.Context, pod *corev1.Pod) FilterResult
+}
+
+// NewPodFilter creates a PodFilter from a function
+func NewPodFilter(name string, fn func(ctx context.Context, pod *corev1.Pod) FilterResult) PodFilter {
+	return &funcFilter{name: name, fn: fn}
+}
+
+// Name implements PodFilter
+func (f *funcFilter) Name() string {
+	return f.name
+}
+
+// Filter implements PodFilter
+func (f *funcFilter) Filter(ctx context.Context, pod *corev1.Pod) FilterResult {
+	return f.fn(ctx, pod)
+}
+
+// include is the result of a filter that has no objection to a pod
+var include = FilterResult{Action: FilterInclude}
+
+// skip returns a result that leaves the pod on the node
+func skip(reason string) FilterResult {
+	return FilterResult{Action: FilterSkip, Reason: reason}
+}
+
+// block returns a result that prevents the node from being drained
+func block(reason string) FilterResult {
+	return FilterResult{Action: FilterBlock, Reason: reason}
+}
+
+// filterTerminating skips pods that are already terminating
+func filterTerminating(_ context.Context, pod *corev1.Pod) FilterResult {
+	if pod.DeletionTimestamp != nil {
+		return skip("pod is already terminating")
+	}
+	return include
+}
+
+// filterMirror skips static pods, which the API server cannot evict
+func filterMirror(_ context.Context, pod *corev1.Pod) FilterResult {
+	if pod.Annotations[corev1.MirrorPodAnnotationKey] != "" {
+		return skip("pod is a mirror pod")
+	}
+	return include
+}
+
+// filterDaemonSet skips DaemonSet pods if configured to ignore them
+func (d *Drainer) filterDaemonSet(_ context.Context, pod *corev1.Pod) FilterResult {
+	if !d.config.IgnoreDaemonSets {
+		return include
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return skip("pod is managed by a DaemonSet")
+		}
+	}
+	return include
+}
+
+// filterLocalStorage skips pods whose local storage would be lost, unless
+// allowed for that kind of storage
+func (d *Drainer) filterLocalStorage(ctx context.Context, pod *corev1.Pod) FilterResult {
+	if reason, ok := d.localStorageReason(ctx, pod); ok {
+		return skip(reason)
+	}
+	return include
+}
+
+// filterUnreplicated skips, or blocks on, pods that nothing would recreate
+func (d *Drainer) filterUnreplicated(ctx context.Context, pod *corev1.Pod) FilterResult {
+	if d.config.EvictUnreplicatedPods {
+		return include
+	}
+	if unreplicated, reason := d.isUnreplicated(ctx, pod); unreplicated {
+		if d.config.BlockOnUnreplicatedPods {
+			return block(reason)
+		}
+		return skip(reason)
+	}
+	return include
+}
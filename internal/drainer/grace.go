@@ -0,0 +1,85 @@
+package drainer
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// GracePeriodAnnotation overrides the termination grace period used when a
+// pod is evicted. It may be set on a pod or on its namespace, with the pod
+// taking precedence, and accepts a duration ("90s", "5m") or a number of
+// seconds. The value is capped at DrainerConfig.GracePeriod.
+const GracePeriodAnnotation = "draino2.kubernetes.io/grace-period"
+
+// effectiveGracePeriod returns the grace period to evict a pod with: the
+// annotation override if present, otherwise the pod's own
+// terminationGracePeriodSeconds, capped at the configured maximum
+func (d *Drainer) effectiveGracePeriod(ctx context.Context, pod *corev1.Pod) time.Duration {
+	grace := time.Duration(corev1.DefaultTerminationGracePeriodSeconds) * time.Second
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		grace = time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+	}
+
+	if override, ok := d.gracePeriodOverride(ctx, pod); ok {
+		grace = override
+	}
+
+	return d.capGracePeriod(grace)
+}
+
+// capGracePeriod clamps a grace period to the range [0, GracePeriod]. A zero
+// GracePeriod means no maximum.
+func (d *Drainer) capGracePeriod(grace time.Duration) time.Duration {
+	if grace < 0 {
+		return 0
+	}
+	if d.config.GracePeriod > 0 && grace > d.config.GracePeriod {
+		return d.config.GracePeriod
+	}
+	return grace
+}
+
+// gracePeriodOverride looks up GracePeriodAnnotation on the pod, then on its namespace
+func (d *Drainer) gracePeriodOverride(ctx context.Context, pod *corev1.Pod) (time.Duration, bool) {
+	log := klog.FromContext(ctx)
+
+	if value, ok := pod.Annotations[GracePeriodAnnotation]; ok {
+		if grace, err := parseGracePeriod(value); err == nil {
+			return grace, true
+		}
+		log.Info("Ignoring invalid grace period annotation on pod", "pod", pod.Name, "namespace", pod.Namespace, "value", value)
+	}
+
+	namespace, err := d.client.CoreV1().Namespaces().Get(ctx, pod.Namespace, metav1.GetOptions{})
+	if err != nil {
+		log.V(2).Info("Failed to get namespace for grace period override", "namespace", pod.Namespace, "error", err)
+		return 0, false
+	}
+	if value, ok := namespace.Annotations[GracePeriodAnnotation]; ok {
+		if grace, err := parseGracePeriod(value); err == nil {
+			return grace, true
+		}
+		log.Info("Ignoring invalid grace period annotation on namespace", "namespace", pod.Namespace, "value", value)
+	}
+
+	return 0, false
+}
+
+// parseGracePeriod parses a duration string or a plain number of seconds
+func parseGracePeriod(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// gracePeriodSeconds converts a grace period for use in DeleteOptions
+func gracePeriodSeconds(grace time.Duration) *int64 {
+	seconds := int64(grace.Seconds())
+	return &seconds
+}
@@ -0,0 +1,240 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// defaultReplacementTimeout bounds the wait for a workload to recover from an
+// eviction when no timeout is configured
+const defaultReplacementTimeout = 10 * time.Minute
+
+// evictionGroup is a unit of work for the eviction worker pool. Pods of a
+// StatefulSet, and of a ReplicaSet in rolling mode, form one serial group and
+// are evicted one at a time; every other pod is a group of its own.
+type evictionGroup struct {
+	// ownerKind and ownerName identify the controller of a serial group
+	ownerKind string
+	ownerName string
+	pods      []corev1.Pod
+}
+
+// serial reports whether the group's pods are evicted one at a time
+func (g *evictionGroup) serial() bool {
+	return g.ownerKind != ""
+}
+
+// evictionGroups groups pods by the controllers whose pods are evicted
+// serially, keeping the order in which each group first appears
+func (d *Drainer) evictionGroups(pods []corev1.Pod) []evictionGroup {
+	var groups []evictionGroup
+	index := make(map[string]int)
+
+	for _, pod := range pods {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || !d.evictsSerially(owner.Kind) {
+			groups = append(groups, evictionGroup{pods: []corev1.Pod{pod}})
+			continue
+		}
+
+		key := owner.Kind + "/" + pod.Namespace + "/" + owner.Name
+		if i, ok := index[key]; ok {
+			groups[i].pods = append(groups[i].pods, pod)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, evictionGroup{ownerKind: owner.Kind, ownerName: owner.Name, pods: []corev1.Pod{pod}})
+	}
+	return groups
+}
+
+// evictsSerially reports whether pods owned by a controller of the given kind
+// are evicted one at a time
+func (d *Drainer) evictsSerially(kind string) bool {
+	switch kind {
+	case "StatefulSet":
+		return true
+	case "ReplicaSet":
+		return d.config.RollingEviction
+	}
+	return false
+}
+
+// evictGroup evicts the pods of a group in order. For serial groups it waits
+// for the owning workload to recover from each eviction before evicting the
+// next pod, so that it never loses more than one replica to the drain at a time.
+func (d *Drainer) evictGroup(ctx context.Context, group evictionGroup, start time.Time) ([]*evictedPod, []evictionFailure) {
+	var (
+		evicted  []*evictedPod
+		failures []evictionFailure
+	)
+
+	for i := range group.pods {
+		pod := group.pods[i]
+		if ctx.Err() != nil {
+			return evicted, append(failures, notAttempted(group.pods[i:], ctx.Err())...)
+		}
+
+		serial := group.serial() && i < len(group.pods)-1
+		var readyBefore int32
+		if serial {
+			ready, err := d.ownerReadyReplicas(ctx, pod.Namespace, group)
+			if err != nil {
+				failures = append(failures, evictionFailure{pod: pod, err: err})
+				return evicted, append(failures, notAttempted(group.pods[i+1:], err)...)
+			}
+			readyBefore = ready
+		}
+
+		grace := d.effectiveGracePeriod(ctx, &pod)
+		if err := d.evictPod(ctx, &pod, grace); err != nil {
+			failures = append(failures, evictionFailure{pod: pod, err: err})
+			if !d.config.Force {
+				return evicted, append(failures, notAttempted(group.pods[i+1:], err)...)
+			}
+			continue
+		}
+
+		ep := &evictedPod{pod: pod, gracePeriod: grace, lastAction: time.Now()}
+		evicted = append(evicted, ep)
+
+		if serial {
+			if err := d.waitForReplacement(ctx, group, ep, readyBefore, start); err != nil {
+				failures = append(failures, evictionFailure{pod: pod, err: err})
+				return evicted, append(failures, notAttempted(group.pods[i+1:], err)...)
+			}
+		}
+	}
+
+	return evicted, failures
+}
+
+// ownerReadyReplicas returns the replica count a serial group's controller
+// must return to after an eviction: ready replicas for a StatefulSet and
+// available replicas for a ReplicaSet. A controller that no longer exists
+// reports zero.
+func (d *Drainer) ownerReadyReplicas(ctx context.Context, namespace string, group evictionGroup) (int32, error) {
+	var (
+		ready int32
+		err   error
+	)
+
+	switch group.ownerKind {
+	case "StatefulSet":
+		var sts *appsv1.StatefulSet
+		if sts, err = d.client.AppsV1().StatefulSets(namespace).Get(ctx, group.ownerName, metav1.GetOptions{}); err == nil {
+			ready = sts.Status.ReadyReplicas
+		}
+	case "ReplicaSet":
+		var rs *appsv1.ReplicaSet
+		if rs, err = d.client.AppsV1().ReplicaSets(namespace).Get(ctx, group.ownerName, metav1.GetOptions{}); err == nil {
+			ready = rs.Status.AvailableReplicas
+		}
+	}
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get %s %s: %w", group.ownerKind, group.ownerName, err)
+	}
+	return ready, nil
+}
+
+// waitForReplacement waits until an evicted pod is gone and its controller is
+// back to readyBefore replicas. For a StatefulSet the replacement pod, which
+// reuses the evicted pod's name, must also be Ready. The evicted pod is
+// escalated while it is still present.
+func (d *Drainer) waitForReplacement(ctx context.Context, group evictionGroup, ep *evictedPod, readyBefore int32, start time.Time) error {
+	log := klog.FromContext(ctx)
+	pod := &ep.pod
+
+	timeout, deadline := d.replacementDeadline(group.ownerKind, start)
+	waitCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	log.Info("Waiting for workload to recover from eviction", "pod", pod.Name, "namespace", pod.Namespace,
+		"owner", group.ownerKind+"/"+group.ownerName, "readyReplicas", readyBefore)
+	err := wait.PollUntilContextCancel(waitCtx, podDeletionPollInterval, false, func(ctx context.Context) (bool, error) {
+		current, err := d.client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			current = nil
+		case err != nil:
+			log.V(2).Info("Failed to get pod while waiting for replacement", "pod", pod.Name, "namespace", pod.Namespace, "error", err)
+			return false, nil
+		case current.UID == pod.UID:
+			if err := d.escalateIfDue(ctx, ep, time.Now()); err != nil {
+				log.Error(err, "Failed to escalate eviction", "pod", pod.Name, "namespace", pod.Namespace)
+			}
+			return false, nil
+		}
+
+		if group.ownerKind == "StatefulSet" && (current == nil || !isPodReady(current)) {
+			return false, nil
+		}
+
+		ready, err := d.ownerReadyReplicas(ctx, pod.Namespace, group)
+		if err != nil {
+			log.V(2).Info("Failed to get controller while waiting for replacement", "owner", group.ownerKind+"/"+group.ownerName, "error", err)
+			return false, nil
+		}
+		return ready >= readyBefore, nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	d.recorder.Eventf(pod, corev1.EventTypeWarning, "ReplacementNotReady",
+		"%s %s did not recover from the eviction of pod %s/%s within %s, not evicting further replicas",
+		group.ownerKind, group.ownerName, pod.Namespace, pod.Name, timeout)
+	return fmt.Errorf("%s %s did not recover from the eviction of pod %s/%s within %s",
+		group.ownerKind, group.ownerName, pod.Namespace, pod.Name, timeout)
+}
+
+// replacementDeadline returns the timeout for a controller of the given kind
+// to recover from an eviction and the deadline it implies. The StatefulSet
+// timeout covers every replacement in the drain, so it counts from start; the
+// rolling eviction timeout applies to each eviction.
+func (d *Drainer) replacementDeadline(kind string, start time.Time) (time.Duration, time.Time) {
+	timeout := d.config.RollingEvictionTimeout
+	from := time.Now()
+	if kind == "StatefulSet" {
+		timeout = d.config.StatefulSetReadyTimeout
+		from = start
+	}
+	if timeout <= 0 {
+		timeout = defaultReplacementTimeout
+	}
+	return timeout, from.Add(timeout)
+}
+
+// isPodReady reports whether the pod's Ready condition is True
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// notAttempted records pods whose eviction was abandoned
+func notAttempted(pods []corev1.Pod, cause error) []evictionFailure {
+	failures := make([]evictionFailure, 0, len(pods))
+	for _, pod := range pods {
+		failures = append(failures, evictionFailure{pod: pod, err: fmt.Errorf("eviction not attempted: %w", cause)})
+	}
+	return failures
+}
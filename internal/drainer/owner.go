@@ -0,0 +1,69 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// isUnreplicated reports whether a pod has no controlling owner, or whether
+// its controlling owner no longer exists, in which case nothing will recreate
+// the pod once it is evicted. The returned string explains why.
+func (d *Drainer) isUnreplicated(ctx context.Context, pod *corev1.Pod) (bool, string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return true, "pod is not managed by a controller"
+	}
+
+	exists, err := d.controllerExists(ctx, pod.Namespace, owner)
+	if err != nil {
+		// Err on the side of treating the pod as replicated; the owner may be
+		// temporarily unreachable and kubectl drain makes the same assumption
+		klog.FromContext(ctx).V(2).Info("Failed to look up pod controller", "pod", pod.Name, "namespace", pod.Namespace,
+			"owner", owner.Kind+"/"+owner.Name, "error", err)
+		return false, ""
+	}
+	if !exists {
+		return true, fmt.Sprintf("controller %s/%s no longer exists", owner.Kind, owner.Name)
+	}
+
+	return false, ""
+}
+
+// controllerExists checks whether the object referenced by a controlling
+// OwnerReference still exists with the same UID. Owners of kinds draino2 does
+// not know how to look up are assumed to exist.
+func (d *Drainer) controllerExists(ctx context.Context, namespace string, owner *metav1.OwnerReference) (bool, error) {
+	var (
+		obj metav1.Object
+		err error
+	)
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		obj, err = d.client.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	case "StatefulSet":
+		obj, err = d.client.AppsV1().StatefulSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	case "DaemonSet":
+		obj, err = d.client.AppsV1().DaemonSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	case "Job":
+		obj, err = d.client.BatchV1().Jobs(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	case "ReplicationController":
+		obj, err = d.client.CoreV1().ReplicationControllers(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	default:
+		return true, nil
+	}
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return obj.GetUID() == owner.UID, nil
+}
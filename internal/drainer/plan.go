@@ -0,0 +1,172 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// DrainPlan describes what draining a node would do, without changing the cluster
+type DrainPlan struct {
+	Node         string        `json:"node"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	WouldCordon  bool          `json:"wouldCordon"`
+	Waves        []PlannedWave `json:"waves"`
+	Skipped      []PlannedPod  `json:"skipped"`
+	Blocked      []PlannedPod  `json:"blocked"`
+	BlockingPDBs []BlockingPDB `json:"blockingPDBs"`
+}
+
+// PlannedWave lists the pods that would be evicted together in one tier
+type PlannedWave struct {
+	Tier string       `json:"tier"`
+	Pods []PlannedPod `json:"pods"`
+}
+
+// PlannedPod is a pod in a drain plan, with the grace period it would be
+// evicted with or the reason it would be left behind
+type PlannedPod struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	GracePeriod string `json:"gracePeriod,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// BlockingPDB is a PodDisruptionBudget that would not allow all of its pods
+// on the node to be evicted at once
+type BlockingPDB struct {
+	Namespace          string   `json:"namespace"`
+	Name               string   `json:"name"`
+	DisruptionsAllowed int32    `json:"disruptionsAllowed"`
+	Pods               []string `json:"pods"`
+}
+
+// Evictions returns the number of pods the plan would evict
+func (p *DrainPlan) Evictions() int {
+	count := 0
+	for _, wave := range p.Waves {
+		count += len(wave.Pods)
+	}
+	return count
+}
+
+// Summary returns a one-line description of the plan
+func (p *DrainPlan) Summary() string {
+	return fmt.Sprintf("would cordon: %t, would evict %d pod(s) in %d wave(s), skip %d pod(s), blocked by %d pod(s) and %d PodDisruptionBudget(s)",
+		p.WouldCordon, p.Evictions(), len(p.Waves), len(p.Skipped), len(p.Blocked), len(p.BlockingPDBs))
+}
+
+// Plan computes what draining the node would do. When cordon is true the
+// plan reports whether the node would be cordoned. Nothing is changed in the
+// cluster. The plan is kept and can be retrieved with LastPlan.
+func (d *Drainer) Plan(ctx context.Context, node *corev1.Node, cordon bool) (*DrainPlan, error) {
+	pods, skipped, blocked, err := d.getPodsOnNode(ctx, node.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pods on node: %w", err)
+	}
+
+	plan := &DrainPlan{
+		Node:        node.Name,
+		CreatedAt:   time.Now().UTC(),
+		WouldCordon: cordon && !d.IsCordoned(node),
+		Skipped:     plannedPods(skipped),
+		Blocked:     plannedPods(blocked),
+	}
+
+	for _, wave := range d.evictionWaves(pods) {
+		planned := PlannedWave{Tier: wave.name}
+		for _, pod := range wave.pods {
+			planned.Pods = append(planned.Pods, PlannedPod{
+				Namespace:   pod.Namespace,
+				Name:        pod.Name,
+				GracePeriod: d.effectiveGracePeriod(ctx, &pod).String(),
+			})
+		}
+		plan.Waves = append(plan.Waves, planned)
+	}
+
+	plan.BlockingPDBs, err = d.blockingPDBs(ctx, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	d.plansLock.Lock()
+	d.plans[node.Name] = plan
+	d.plansLock.Unlock()
+
+	return plan, nil
+}
+
+// LastPlan returns the most recent plan computed for a node
+func (d *Drainer) LastPlan(nodeName string) (*DrainPlan, bool) {
+	d.plansLock.RLock()
+	defer d.plansLock.RUnlock()
+	plan, ok := d.plans[nodeName]
+	return plan, ok
+}
+
+// blockingPDBs finds PodDisruptionBudgets that allow fewer disruptions than
+// the number of their pods that would be evicted
+func (d *Drainer) blockingPDBs(ctx context.Context, pods []corev1.Pod) ([]BlockingPDB, error) {
+	byNamespace := make(map[string][]corev1.Pod)
+	for _, pod := range pods {
+		byNamespace[pod.Namespace] = append(byNamespace[pod.Namespace], pod)
+	}
+
+	var result []BlockingPDB
+	for namespace, nsPods := range byNamespace {
+		pdbs, err := d.client.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PodDisruptionBudgets in namespace %s: %w", namespace, err)
+		}
+
+		for _, pdb := range pdbs.Items {
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil {
+				klog.FromContext(ctx).V(2).Info("Ignoring PodDisruptionBudget with invalid selector",
+					"pdb", pdb.Name, "namespace", namespace, "error", err)
+				continue
+			}
+
+			var matched []string
+			for _, pod := range nsPods {
+				if selector.Matches(labels.Set(pod.Labels)) {
+					matched = append(matched, pod.Namespace+"/"+pod.Name)
+				}
+			}
+			if len(matched) > int(pdb.Status.DisruptionsAllowed) {
+				result = append(result, BlockingPDB{
+					Namespace:          namespace,
+					Name:               pdb.Name,
+					DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+					Pods:               matched,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// RecordPlan emits a drain plan as a node event and logs it in full
+func (d *Drainer) RecordPlan(ctx context.Context, node *corev1.Node, plan *DrainPlan) {
+	log := klog.FromContext(ctx)
+	log.Info("Dry run: computed drain plan", "node", node.Name, "wouldCordon", plan.WouldCordon,
+		"waves", plan.Waves, "skipped", plan.Skipped, "blocked", plan.Blocked, "blockingPDBs", plan.BlockingPDBs)
+	d.recorder.Eventf(node, corev1.EventTypeNormal, "DrainPlanned",
+		"Dry run for node %s: %s", node.Name, plan.Summary())
+}
+
+// plannedPods converts skipped or blocked pods for a plan
+func plannedPods(pods []skippedPod) []PlannedPod {
+	result := make([]PlannedPod, 0, len(pods))
+	for _, p := range pods {
+		result = append(result, PlannedPod{Namespace: p.pod.Namespace, Name: p.pod.Name, Reason: p.reason})
+	}
+	return result
+}
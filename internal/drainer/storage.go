@@ -0,0 +1,59 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// localStorageReason checks a pod's volumes against the local storage policy.
+// It returns the reason the pod must be left on the node and true if any of
+// its volumes is node-local storage that the policy does not allow losing.
+func (d *Drainer) localStorageReason(ctx context.Context, pod *corev1.Pod) (string, bool) {
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.EmptyDir != nil && volume.EmptyDir.Medium == corev1.StorageMediumMemory:
+			if !d.config.DeleteMemoryEmptyDirData {
+				return fmt.Sprintf("pod uses memory-backed emptyDir volume %s", volume.Name), true
+			}
+		case volume.EmptyDir != nil:
+			if !d.config.DeleteEmptyDirData {
+				return fmt.Sprintf("pod uses emptyDir volume %s", volume.Name), true
+			}
+		case volume.HostPath != nil:
+			if !d.config.EvictHostPathPods {
+				return fmt.Sprintf("pod uses hostPath volume %s", volume.Name), true
+			}
+		case volume.PersistentVolumeClaim != nil:
+			if !d.config.EvictLocalPVPods && d.isLocalPersistentVolume(ctx, pod.Namespace, volume.PersistentVolumeClaim.ClaimName) {
+				return fmt.Sprintf("pod uses local PersistentVolume through claim %s", volume.PersistentVolumeClaim.ClaimName), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isLocalPersistentVolume reports whether a claim is bound to a local PersistentVolume
+func (d *Drainer) isLocalPersistentVolume(ctx context.Context, namespace, claimName string) bool {
+	log := klog.FromContext(ctx)
+
+	pvc, err := d.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		log.V(2).Info("Failed to get PersistentVolumeClaim", "claim", claimName, "namespace", namespace, "error", err)
+		return false
+	}
+	if pvc.Spec.VolumeName == "" {
+		return false
+	}
+
+	pv, err := d.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		log.V(2).Info("Failed to get PersistentVolume", "volume", pvc.Spec.VolumeName, "error", err)
+		return false
+	}
+
+	return pv.Spec.Local != nil
+}
@@ -0,0 +1,148 @@
+package drainer
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EvictionTier groups pods that are evicted in the same wave. A pod matches a
+// tier if it matches any of the tier's selectors. A tier without selectors
+// matches every pod that no other tier matched.
+type EvictionTier struct {
+	// Name identifies the tier in logs and events
+	Name string
+	// PriorityClassNames matches pods by priority class
+	PriorityClassNames []string
+	// MinPriority matches pods whose priority is at least this value
+	MinPriority *int32
+	// MaxPriority matches pods whose priority is at most this value
+	MaxPriority *int32
+	// OwnerKinds matches pods by the kind of their controlling owner, e.g. "Job"
+	OwnerKinds []string
+}
+
+// systemCriticalPriority is the lowest priority of the built-in
+// system-cluster-critical and system-node-critical priority classes
+const systemCriticalPriority int32 = 2000000000
+
+// DefaultEvictionTiers evicts batch and negative-priority pods first, then
+// regular workloads, then high-priority and system-critical pods last
+var DefaultEvictionTiers = []EvictionTier{
+	{
+		Name:        "batch",
+		MaxPriority: &[]int32{-1}[0],
+		OwnerKinds:  []string{"Job"},
+	},
+	{
+		Name: "default",
+	},
+	{
+		Name:               "critical",
+		PriorityClassNames: []string{"system-cluster-critical", "system-node-critical"},
+		MinPriority:        &[]int32{systemCriticalPriority}[0],
+	},
+}
+
+// evictionWave is a set of pods evicted together
+type evictionWave struct {
+	name string
+	pods []corev1.Pod
+}
+
+// hasSelectors reports whether the tier selects pods explicitly
+func (t *EvictionTier) hasSelectors() bool {
+	return len(t.PriorityClassNames) > 0 || t.MinPriority != nil || t.MaxPriority != nil || len(t.OwnerKinds) > 0
+}
+
+// matches reports whether any of the tier's selectors match the pod
+func (t *EvictionTier) matches(pod *corev1.Pod) bool {
+	for _, name := range t.PriorityClassNames {
+		if pod.Spec.PriorityClassName == name {
+			return true
+		}
+	}
+
+	var priority int32
+	if pod.Spec.Priority != nil {
+		priority = *pod.Spec.Priority
+	}
+	if t.MinPriority != nil && priority >= *t.MinPriority {
+		return true
+	}
+	if t.MaxPriority != nil && priority <= *t.MaxPriority {
+		return true
+	}
+
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		for _, kind := range t.OwnerKinds {
+			if owner.Kind == kind {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// evictionTiers returns the configured tiers, or the defaults
+func (d *Drainer) evictionTiers() []EvictionTier {
+	if len(d.config.EvictionTiers) > 0 {
+		return d.config.EvictionTiers
+	}
+	return DefaultEvictionTiers
+}
+
+// evictionWaves splits pods into the order they are evicted in: one wave per
+// eviction tier, followed by pods that match no tier, followed by pods
+// annotated to be evicted last. Empty waves are dropped.
+func (d *Drainer) evictionWaves(pods []corev1.Pod) []evictionWave {
+	tiers := d.evictionTiers()
+
+	waves := make([]evictionWave, len(tiers)+2)
+	for i, tier := range tiers {
+		waves[i].name = tier.Name
+	}
+	unmatched := &waves[len(tiers)]
+	unmatched.name = "unmatched"
+	last := &waves[len(tiers)+1]
+	last.name = "last"
+
+	for _, pod := range pods {
+		if evictAnnotation(&pod) == EvictLast {
+			last.pods = append(last.pods, pod)
+			continue
+		}
+		if i := tierIndex(tiers, &pod); i >= 0 {
+			waves[i].pods = append(waves[i].pods, pod)
+		} else {
+			unmatched.pods = append(unmatched.pods, pod)
+		}
+	}
+
+	var result []evictionWave
+	for _, wave := range waves {
+		if len(wave.pods) > 0 {
+			result = append(result, wave)
+		}
+	}
+	return result
+}
+
+// tierIndex returns the index of the tier a pod belongs to. Pods are matched
+// against tiers with selectors in order, then fall back to the first tier
+// without selectors. It returns -1 if no tier applies.
+func tierIndex(tiers []EvictionTier, pod *corev1.Pod) int {
+	fallback := -1
+	for i := range tiers {
+		if !tiers[i].hasSelectors() {
+			if fallback < 0 {
+				fallback = i
+			}
+			continue
+		}
+		if tiers[i].matches(pod) {
+			return i
+		}
+	}
+	return fallback
+}
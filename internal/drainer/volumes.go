@@ -0,0 +1,157 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const defaultVolumeDetachTimeout = 5 * time.Minute
+
+// volumeDetachPollInterval is how often VolumeAttachments are checked
+var volumeDetachPollInterval = 5 * time.Second
+
+// VolumesAttachedError is returned when volumes are still attached to a node
+// after the volume detach timeout
+type VolumesAttachedError struct {
+	Node    string
+	Volumes []string
+}
+
+// Error implements the error interface
+func (e *VolumesAttachedError) Error() string {
+	return fmt.Sprintf("timed out waiting for %d volume(s) to detach from node %s: %s",
+		len(e.Volumes), e.Node, strings.Join(e.Volumes, ", "))
+}
+
+// WaitForVolumeDetach waits until no VolumeAttachment references the node,
+// so that it can be safely rebooted or terminated. Volumes still used by pods
+// left on the node, such as DaemonSet pods or pods the drain skipped, are not
+// waited for since they never detach. It returns a VolumesAttachedError naming
+// the volumes still attached after VolumeDetachTimeout.
+func (d *Drainer) WaitForVolumeDetach(ctx context.Context, node *corev1.Node) error {
+	log := klog.FromContext(ctx)
+
+	timeout := d.config.VolumeDetachTimeout
+	if timeout <= 0 {
+		timeout = defaultVolumeDetachTimeout
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var attached []string
+	log.Info("Waiting for volumes to detach", "node", node.Name, "timeout", timeout)
+	err := wait.PollUntilContextCancel(waitCtx, volumeDetachPollInterval, true, func(ctx context.Context) (bool, error) {
+		attachments, err := d.client.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.V(2).Info("Failed to list VolumeAttachments", "node", node.Name, "error", err)
+			return false, nil
+		}
+		inUse, err := d.volumesInUse(ctx, node.Name)
+		if err != nil {
+			log.V(2).Info("Failed to find volumes used by pods left on the node", "node", node.Name, "error", err)
+			return false, nil
+		}
+		attached = attachedVolumes(attachments.Items, node.Name, inUse)
+		return len(attached) == 0, nil
+	})
+	if err == nil {
+		log.Info("All volumes detached", "node", node.Name)
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	attachedErr := &VolumesAttachedError{Node: node.Name, Volumes: attached}
+	d.recorder.Eventf(node, corev1.EventTypeWarning, "VolumeDetachTimeout",
+		"%d volume(s) still attached to node %s after %s: %s", len(attached), node.Name, timeout, strings.Join(attached, ", "))
+	return attachedErr
+}
+
+// volumeUsage records the volumes used by pods left on a node
+type volumeUsage struct {
+	// persistentVolumes holds the PersistentVolumes bound to the pods' claims
+	persistentVolumes map[string]bool
+	// inlineDrivers holds the drivers of the pods' inline CSI volumes, which
+	// cannot be matched to a VolumeAttachment by name
+	inlineDrivers map[string]bool
+}
+
+// volumesInUse returns the volumes used by pods still running on the node.
+// Pods that are terminating or have finished are ignored, since their volumes
+// are about to be detached.
+func (d *Drainer) volumesInUse(ctx context.Context, nodeName string) (volumeUsage, error) {
+	usage := volumeUsage{persistentVolumes: make(map[string]bool), inlineDrivers: make(map[string]bool)}
+
+	pods, err := d.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return usage, fmt.Errorf("failed to list pods on node: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			var claim string
+			switch {
+			case volume.PersistentVolumeClaim != nil:
+				claim = volume.PersistentVolumeClaim.ClaimName
+			case volume.Ephemeral != nil:
+				claim = pod.Name + "-" + volume.Name
+			case volume.CSI != nil:
+				usage.inlineDrivers[volume.CSI.Driver] = true
+				continue
+			default:
+				continue
+			}
+
+			pvc, err := d.client.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(ctx, claim, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return usage, fmt.Errorf("failed to get PersistentVolumeClaim %s/%s: %w", pod.Namespace, claim, err)
+			}
+			if pvc.Spec.VolumeName != "" {
+				usage.persistentVolumes[pvc.Spec.VolumeName] = true
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+// attachedVolumes returns the volumes attached to a node that are not in use
+// by pods left on it, named by their PersistentVolume where there is one and
+// by the VolumeAttachment otherwise
+func attachedVolumes(attachments []storagev1.VolumeAttachment, nodeName string, inUse volumeUsage) []string {
+	var volumes []string
+	for _, va := range attachments {
+		if va.Spec.NodeName != nodeName {
+			continue
+		}
+		if pv := va.Spec.Source.PersistentVolumeName; pv != nil && *pv != "" {
+			if !inUse.persistentVolumes[*pv] {
+				volumes = append(volumes, *pv)
+			}
+			continue
+		}
+		if !inUse.inlineDrivers[va.Spec.Attacher] {
+			volumes = append(volumes, va.Name)
+		}
+	}
+	return volumes
+}
@@ -0,0 +1,81 @@
+package drainer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// podDeletionPollInterval is how often evicted pods are checked for termination
+var podDeletionPollInterval = 2 * time.Second
+
+// PodsNotTerminatedError is returned when evicted pods are still present on a
+// node after the drain timeout has expired
+type PodsNotTerminatedError struct {
+	Node string
+	Pods []string
+}
+
+// Error implements the error interface
+func (e *PodsNotTerminatedError) Error() string {
+	return fmt.Sprintf("timed out waiting for %d pod(s) to terminate on node %s: %s",
+		len(e.Pods), e.Node, strings.Join(e.Pods, ", "))
+}
+
+// waitForPodsDeleted waits until all given pods are gone from the cluster or
+// the context expires, escalating pods that outlive their eviction according
+// to the configured EscalationPolicy. A pod that was recreated with the same
+// name counts as gone, since its UID changes. It returns the pods that were
+// still present.
+func (d *Drainer) waitForPodsDeleted(ctx context.Context, pods []*evictedPod) ([]corev1.Pod, error) {
+	log := klog.FromContext(ctx)
+	pending := pods
+
+	err := wait.PollUntilContextCancel(ctx, podDeletionPollInterval, true, func(ctx context.Context) (bool, error) {
+		var remaining []*evictedPod
+		for _, ep := range pending {
+			pod := &ep.pod
+			current, err := d.client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				// Treat lookup errors as transient and check again on the next poll
+				log.V(2).Info("Failed to get pod while waiting for termination", "pod", pod.Name, "namespace", pod.Namespace, "error", err)
+				remaining = append(remaining, ep)
+				continue
+			}
+			if current.UID != pod.UID {
+				continue
+			}
+			if err := d.escalateIfDue(ctx, ep, time.Now()); err != nil {
+				log.Error(err, "Failed to escalate eviction", "pod", pod.Name, "namespace", pod.Namespace)
+			}
+			remaining = append(remaining, ep)
+		}
+		pending = remaining
+		return len(pending) == 0, nil
+	})
+
+	remainingPods := make([]corev1.Pod, 0, len(pending))
+	for _, ep := range pending {
+		remainingPods = append(remainingPods, ep.pod)
+	}
+	return remainingPods, err
+}
+
+// podNames returns the namespace/name of each pod
+func podNames(pods []corev1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
@@ -0,0 +1,263 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+// Stage is a point in a drain at which hooks are called
+type Stage string
+
+const (
+	// StagePreCordon runs before the node is cordoned
+	StagePreCordon Stage = "preCordon"
+	// StagePostCordon runs after the node is cordoned, before pods are evicted
+	StagePostCordon Stage = "postCordon"
+	// StagePostDrain runs after all evictions have completed
+	StagePostDrain Stage = "postDrain"
+	// StageFailure runs after a drain has failed
+	StageFailure Stage = "onFailure"
+)
+
+const (
+	defaultTimeout       = 10 * time.Second
+	defaultRetryInterval = 1 * time.Second
+	defaultDelay         = 1 * time.Minute
+	// maxMessageBytes limits how much of a response body is kept for errors
+	maxMessageBytes = 4096
+)
+
+// Payload is the JSON body sent to hooks
+type Payload struct {
+	Stage     Stage     `json:"stage"`
+	Node      string    `json:"node"`
+	Reason    string    `json:"reason"`
+	Pods      []string  `json:"pods"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// VetoError is returned when a hook responds with 409 Conflict to stop the drain
+type VetoError struct {
+	Hook    string
+	Stage   Stage
+	Message string
+}
+
+// Error implements the error interface
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("hook %s vetoed the drain at stage %s: %s", e.Hook, e.Stage, e.Message)
+}
+
+// DelayError is returned when a hook responds with 429 Too Many Requests to
+// postpone the drain. After is taken from the Retry-After header.
+type DelayError struct {
+	Hook  string
+	Stage Stage
+	After time.Duration
+}
+
+// Error implements the error interface
+func (e *DelayError) Error() string {
+	return fmt.Sprintf("hook %s delayed the drain at stage %s for %s", e.Hook, e.Stage, e.After)
+}
+
+// HookError is returned when a hook could not be called successfully after all retries
+type HookError struct {
+	Hook  string
+	Stage Stage
+	Err   error
+}
+
+// Error implements the error interface
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s failed at stage %s: %v", e.Hook, e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Runner calls the configured HTTP hooks
+type Runner struct {
+	hooks  []types.HookConfig
+	client *http.Client
+}
+
+// ValidateHooks checks that every hook names only known stages
+func ValidateHooks(hooks []types.HookConfig) error {
+	for _, hook := range hooks {
+		for _, stage := range hook.Stages {
+			switch Stage(stage) {
+			case StagePreCordon, StagePostCordon, StagePostDrain, StageFailure:
+			default:
+				return fmt.Errorf("hook %s has unknown stage %q", hook.Name, stage)
+			}
+		}
+	}
+	return nil
+}
+
+// NewRunner creates a runner for the given hooks
+func NewRunner(hooks []types.HookConfig) *Runner {
+	return &Runner{
+		hooks:  hooks,
+		client: &http.Client{},
+	}
+}
+
+// Configured reports whether any hook is configured. A nil Runner has none.
+func (r *Runner) Configured() bool {
+	return r != nil && len(r.hooks) > 0
+}
+
+// Handles reports whether any hook is registered for the stage
+func (r *Runner) Handles(stage Stage) bool {
+	if r == nil {
+		return false
+	}
+	for _, hook := range r.hooks {
+		if handlesStage(hook, stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run calls every hook registered for the stage, in order, and stops at the
+// first one that vetoes, delays or fails. A nil Runner does nothing.
+func (r *Runner) Run(ctx context.Context, stage Stage, payload Payload) error {
+	if r == nil {
+		return nil
+	}
+
+	payload.Stage = stage
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now().UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode hook payload: %w", err)
+	}
+
+	for _, hook := range r.hooks {
+		if !handlesStage(hook, stage) {
+			continue
+		}
+		if err := r.call(ctx, hook, stage, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call sends the payload to one hook, retrying transport errors and
+// unexpected status codes
+func (r *Runner) call(ctx context.Context, hook types.HookConfig, stage Stage, body []byte) error {
+	log := klog.FromContext(ctx)
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	retryInterval := hook.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultRetryInterval
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= hook.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return &HookError{Hook: hook.Name, Stage: stage, Err: ctx.Err()}
+			case <-time.After(retryInterval):
+			}
+		}
+
+		status, header, message, err := r.post(ctx, hook.URL, timeout, body)
+		if err != nil {
+			log.Info("Hook call failed", "hook", hook.Name, "stage", stage, "attempt", attempt+1, "error", err)
+			lastErr = err
+			continue
+		}
+
+		switch {
+		case status >= 200 && status < 300:
+			log.V(2).Info("Hook call succeeded", "hook", hook.Name, "stage", stage, "status", status)
+			return nil
+		case status == http.StatusConflict:
+			return &VetoError{Hook: hook.Name, Stage: stage, Message: message}
+		case status == http.StatusTooManyRequests:
+			return &DelayError{Hook: hook.Name, Stage: stage, After: retryAfter(header)}
+		default:
+			log.Info("Hook returned unexpected status", "hook", hook.Name, "stage", stage, "attempt", attempt+1, "status", status)
+			lastErr = fmt.Errorf("unexpected status %d: %s", status, message)
+		}
+	}
+
+	return &HookError{Hook: hook.Name, Stage: stage, Err: lastErr}
+}
+
+// post sends a single request and returns the status, headers and a
+// truncated response body
+func (r *Runner) post(ctx context.Context, url string, timeout time.Duration, body []byte) (int, http.Header, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	message, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageBytes))
+	if err != nil {
+		return 0, nil, "", err
+	}
+	return resp.StatusCode, resp.Header, string(bytes.TrimSpace(message)), nil
+}
+
+// handlesStage reports whether a hook is registered for the stage
+func handlesStage(hook types.HookConfig, stage Stage) bool {
+	for _, s := range hook.Stages {
+		if Stage(s) == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header as seconds or an HTTP date
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return defaultDelay
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return defaultDelay
+}
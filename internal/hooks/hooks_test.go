@@ -0,0 +1,185 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nfelsen/draino2/internal/types"
+)
+
+func newTestRunner(url string, stages []string, retries int) *Runner {
+	return NewRunner([]types.HookConfig{{
+		Name:          "test",
+		URL:           url,
+		Stages:        stages,
+		Timeout:       time.Second,
+		Retries:       retries,
+		RetryInterval: time.Millisecond,
+	}})
+}
+
+func TestRunSendsPayload(t *testing.T) {
+	var got Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	runner := newTestRunner(server.URL, []string{"preCordon"}, 0)
+	err := runner.Run(context.Background(), StagePreCordon, Payload{Node: "node-1", Reason: "maintenance", Pods: []string{"default/web"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got.Stage != StagePreCordon || got.Node != "node-1" || got.Reason != "maintenance" || len(got.Pods) != 1 {
+		t.Errorf("Unexpected payload: %+v", got)
+	}
+}
+
+func TestRunSkipsOtherStages(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	runner := newTestRunner(server.URL, []string{"postDrain"}, 0)
+	if err := runner.Run(context.Background(), StagePreCordon, Payload{Node: "node-1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no calls, got %d", calls)
+	}
+	if runner.Handles(StagePreCordon) || !runner.Handles(StagePostDrain) {
+		t.Error("Handles does not match the configured stages")
+	}
+}
+
+func TestRunVeto(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte("change freeze"))
+	}))
+	defer server.Close()
+
+	runner := newTestRunner(server.URL, []string{"preCordon"}, 3)
+	err := runner.Run(context.Background(), StagePreCordon, Payload{Node: "node-1"})
+
+	var vetoErr *VetoError
+	if !errors.As(err, &vetoErr) {
+		t.Fatalf("Expected VetoError, got %v", err)
+	}
+	if vetoErr.Message != "change freeze" {
+		t.Errorf("Expected message %q, got %q", "change freeze", vetoErr.Message)
+	}
+}
+
+func TestRunDelay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	runner := newTestRunner(server.URL, []string{"postCordon"}, 0)
+	err := runner.Run(context.Background(), StagePostCordon, Payload{Node: "node-1"})
+
+	var delayErr *DelayError
+	if !errors.As(err, &delayErr) {
+		t.Fatalf("Expected DelayError, got %v", err)
+	}
+	if delayErr.After != 2*time.Minute {
+		t.Errorf("Expected delay of 2m, got %s", delayErr.After)
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	runner := newTestRunner(server.URL, []string{"preCordon"}, 2)
+	if err := runner.Run(context.Background(), StagePreCordon, Payload{Node: "node-1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRunFailsAfterRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	runner := newTestRunner(server.URL, []string{"onFailure"}, 1)
+	err := runner.Run(context.Background(), StageFailure, Payload{Node: "node-1"})
+
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) {
+		t.Fatalf("Expected HookError, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestNilRunner(t *testing.T) {
+	var runner *Runner
+	if err := runner.Run(context.Background(), StagePreCordon, Payload{}); err != nil {
+		t.Errorf("Expected nil runner to do nothing, got %v", err)
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	valid := []types.HookConfig{{Name: "all", Stages: []string{"preCordon", "postCordon", "postDrain", "onFailure"}}}
+	if err := ValidateHooks(valid); err != nil {
+		t.Errorf("Expected known stages to be valid, got %v", err)
+	}
+
+	invalid := []types.HookConfig{{Name: "typo", Stages: []string{"postdrain"}}}
+	if err := ValidateHooks(invalid); err == nil {
+		t.Error("Expected an unknown stage to be rejected")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", defaultDelay},
+		{"30", 30 * time.Second},
+		{"garbage", defaultDelay},
+		{"0", defaultDelay},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		if got := retryAfter(header); got != tt.expected {
+			t.Errorf("retryAfter(%q) = %s, expected %s", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -13,12 +13,18 @@ type Metrics struct {
 	DrainOperationsCompleted prometheus.Counter
 	// DrainOperationsFailed tracks the number of drain operations that failed
 	DrainOperationsFailed prometheus.Counter
+	// DrainOperationsCancelled tracks the number of drain operations cancelled while in progress
+	DrainOperationsCancelled prometheus.Counter
+	// DrainOperationsStuck tracks the number of drain operations stopped for exceeding the maximum drain duration
+	DrainOperationsStuck prometheus.Counter
 	// DrainDuration tracks the duration of drain operations
 	DrainDuration prometheus.Histogram
 	// PodsEvicted tracks the number of pods evicted during drains
 	PodsEvicted prometheus.Counter
 	// PodsFailedToEvict tracks the number of pods that failed to evict
 	PodsFailedToEvict prometheus.Counter
+	// PodEvictionEscalations tracks evictions escalated to a direct delete, by action
+	PodEvictionEscalations *prometheus.CounterVec
 	// NodesCordoned tracks the number of nodes cordoned
 	NodesCordoned prometheus.Counter
 	// NodesUncordoned tracks the number of nodes uncordoned
@@ -42,6 +48,14 @@ func NewMetrics() *Metrics {
 			Name: "draino2_drain_operations_failed_total",
 			Help: "Total number of drain operations that failed",
 		}),
+		DrainOperationsCancelled: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "draino2_drain_operations_cancelled_total",
+			Help: "Total number of drain operations cancelled while in progress",
+		}),
+		DrainOperationsStuck: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "draino2_drain_operations_stuck_total",
+			Help: "Total number of drain operations stopped for exceeding the maximum drain duration",
+		}),
 		DrainDuration: promauto.NewHistogram(prometheus.HistogramOpts{
 			Name:    "draino2_drain_duration_seconds",
 			Help:    "Duration of drain operations in seconds",
@@ -55,6 +69,10 @@ func NewMetrics() *Metrics {
 			Name: "draino2_pods_failed_to_evict_total",
 			Help: "Total number of pods that failed to evict during drain operations",
 		}),
+		PodEvictionEscalations: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "draino2_pod_eviction_escalations_total",
+			Help: "Total number of pod evictions escalated to a direct delete",
+		}, []string{"action"}),
 		NodesCordoned: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "draino2_nodes_cordoned_total",
 			Help: "Total number of nodes cordoned",
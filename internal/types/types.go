@@ -6,13 +6,22 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-// LabelTrigger defines a label that can trigger a drain operation
+// LabelTrigger defines a label that can trigger a drain operation, or exclude
+// a node from draining. Without an Operator the label must exist and, if Value
+// is set, equal it. Operator is one of In, NotIn, Exists or DoesNotExist and
+// uses Values; a drain trigger must carry the label, so it cannot use
+// DoesNotExist and NotIn only matches nodes that have the label. Regex, if
+// set, must match the whole label value.
 type LabelTrigger struct {
-	Key   string `json:"key" yaml:"key"`
-	Value string `json:"value" yaml:"value"`
+	Key      string   `json:"key" yaml:"key"`
+	Value    string   `json:"value" yaml:"value"`
+	Operator string   `json:"operator" yaml:"operator"`
+	Values   []string `json:"values" yaml:"values"`
+	Regex    string   `json:"regex" yaml:"regex"`
 }
 
 // NodeCondition defines a node condition that can trigger a drain operation
+// once it has had Status (True if empty) for at least MinimumDuration
 type NodeCondition struct {
 	Type            corev1.NodeConditionType `json:"type" yaml:"type"`
 	Status          corev1.ConditionStatus   `json:"status" yaml:"status"`
@@ -28,6 +37,139 @@ type DrainSettings struct {
 	EvictDaemonSetPods    bool          `json:"evictDaemonSetPods" yaml:"evictDaemonSetPods"`
 	EvictLocalStoragePods bool          `json:"evictLocalStoragePods" yaml:"evictLocalStoragePods"`
 	EvictUnreplicatedPods bool          `json:"evictUnreplicatedPods" yaml:"evictUnreplicatedPods"`
+	// EvictMemoryEmptyDirPods allows eviction of pods with memory-backed emptyDir volumes
+	EvictMemoryEmptyDirPods bool `json:"evictMemoryEmptyDirPods" yaml:"evictMemoryEmptyDirPods"`
+	// EvictHostPathPods allows eviction of pods with hostPath volumes
+	EvictHostPathPods bool `json:"evictHostPathPods" yaml:"evictHostPathPods"`
+	// EvictLocalPVPods allows eviction of pods using local PersistentVolumes
+	EvictLocalPVPods bool `json:"evictLocalPVPods" yaml:"evictLocalPVPods"`
+	// ContinueOnEvictionFailure keeps draining a node when individual pod evictions fail
+	ContinueOnEvictionFailure bool `json:"continueOnEvictionFailure" yaml:"continueOnEvictionFailure"`
+	// BlockOnUnreplicatedPods refuses to drain nodes running pods without a
+	// controller, instead of skipping those pods, unless EvictUnreplicatedPods is set
+	BlockOnUnreplicatedPods bool `json:"blockOnUnreplicatedPods" yaml:"blockOnUnreplicatedPods"`
+	// EvictionFallback escalates evictions that have not completed after the
+	// grace period plus EvictionHeadroom: "none", "delete" or "force-delete"
+	EvictionFallback string `json:"evictionFallback" yaml:"evictionFallback"`
+	// PodFilters names the filters, in order, that decide whether a pod is evicted
+	PodFilters []string `json:"podFilters" yaml:"podFilters"`
+	// EvictionTiers orders evictions into waves; each wave terminates before the next starts
+	EvictionTiers []EvictionTier `json:"evictionTiers" yaml:"evictionTiers"`
+	// MaxConcurrentEvictions limits how many pods are evicted in parallel per node
+	MaxConcurrentEvictions int `json:"maxConcurrentEvictions" yaml:"maxConcurrentEvictions"`
+	// StatefulSetReadyTimeout is how long a drain may wait in total for
+	// replacement StatefulSet pods to become Ready between replica evictions
+	StatefulSetReadyTimeout time.Duration `json:"statefulSetReadyTimeout" yaml:"statefulSetReadyTimeout"`
+	// RollingEviction evicts Deployment and ReplicaSet pods one at a time,
+	// waiting for availableReplicas to recover between evictions
+	RollingEviction bool `json:"rollingEviction" yaml:"rollingEviction"`
+	// RollingEvictionTimeout is how long to wait for a ReplicaSet to recover in rolling mode
+	RollingEvictionTimeout time.Duration `json:"rollingEvictionTimeout" yaml:"rollingEvictionTimeout"`
+	// CordonMode is "unschedulable" to set spec.unschedulable or "taint" to add CordonTaint
+	CordonMode string `json:"cordonMode" yaml:"cordonMode"`
+	// CordonTaint is the taint added in "taint" cordon mode
+	CordonTaint CordonTaint `json:"cordonTaint" yaml:"cordonTaint"`
+	// UncordonOnCancel uncordons a node when its drain is cancelled, if draino2 cordoned it
+	UncordonOnCancel bool `json:"uncordonOnCancel" yaml:"uncordonOnCancel"`
+	// MaxConcurrentDrains limits how many nodes are drained at the same time;
+	// zero means no limit
+	MaxConcurrentDrains int `json:"maxConcurrentDrains" yaml:"maxConcurrentDrains"`
+	// MaxUnschedulablePercent limits the percentage of nodes that may be
+	// unschedulable, counting nodes cordoned by anyone; zero means no limit
+	MaxUnschedulablePercent int `json:"maxUnschedulablePercent" yaml:"maxUnschedulablePercent"`
+	// TopologyBudgets limit how many nodes sharing a label value, such as a
+	// zone or node pool, are drained at the same time
+	TopologyBudgets []TopologyBudget `json:"topologyBudgets" yaml:"topologyBudgets"`
+	// ReconcileWorkers is how many nodes are reconciled at the same time.
+	// Each drain occupies a worker for its duration, so there is always at
+	// least one worker more than MaxConcurrentDrains; zero uses the default
+	ReconcileWorkers int `json:"reconcileWorkers" yaml:"reconcileWorkers"`
+	// MaxDrainDuration stops drains that have been in progress longer than
+	// this and marks them failed; zero disables the check
+	MaxDrainDuration time.Duration `json:"maxDrainDuration" yaml:"maxDrainDuration"`
+	// DrainRetry is the retry policy for failed drains
+	DrainRetry DrainRetry `json:"drainRetry" yaml:"drainRetry"`
+	// DrainRecovery decides what happens to drains interrupted by a controller restart
+	DrainRecovery DrainRecovery `json:"drainRecovery" yaml:"drainRecovery"`
+	// WaitForVolumeDetach waits for all VolumeAttachments on the node to be
+	// removed before marking it drained
+	WaitForVolumeDetach bool `json:"waitForVolumeDetach" yaml:"waitForVolumeDetach"`
+	// VolumeDetachTimeout is how long to wait for volumes to detach
+	VolumeDetachTimeout time.Duration `json:"volumeDetachTimeout" yaml:"volumeDetachTimeout"`
+	// CapacityCheck verifies evicted pods fit on the remaining nodes before draining
+	CapacityCheck CapacityCheck `json:"capacityCheck" yaml:"capacityCheck"`
+	// EvictionRetry configures backoff for evictions blocked by a PodDisruptionBudget
+	EvictionRetry EvictionRetry `json:"evictionRetry" yaml:"evictionRetry"`
+}
+
+// DrainRetry configures how often, and how soon, a failed drain is retried
+// while its trigger is still present
+type DrainRetry struct {
+	MaxRetries    int           `json:"maxRetries" yaml:"maxRetries"`
+	RetryInterval time.Duration `json:"retryInterval" yaml:"retryInterval"`
+}
+
+// DrainRecovery configures how drains left in progress by a previous
+// controller instance are handled. When Resume is set, drains that started
+// at most MaxAge ago (any age if zero) are resumed; all others are marked failed.
+type DrainRecovery struct {
+	Resume bool          `json:"resume" yaml:"resume"`
+	MaxAge time.Duration `json:"maxAge" yaml:"maxAge"`
+}
+
+// TopologyBudget limits concurrent drains per value of a node label
+type TopologyBudget struct {
+	// LabelKey groups nodes by their value for this label, e.g. topology.kubernetes.io/zone
+	LabelKey string `json:"labelKey" yaml:"labelKey"`
+	// MaxDraining is the number of nodes per group that may be draining at once
+	MaxDraining int `json:"maxDraining" yaml:"maxDraining"`
+}
+
+// CordonTaint is the draino2-owned taint used to cordon nodes in "taint" mode
+type CordonTaint struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Effect string `json:"effect" yaml:"effect"`
+	// NoExecute also adds the taint with the NoExecute effect
+	NoExecute bool `json:"noExecute" yaml:"noExecute"`
+}
+
+// CapacityCheck configures the pre-drain rescheduling simulation. When the
+// pods do not fit, the drain is retried after DeferInterval, or refused if
+// DeferInterval is zero.
+type CapacityCheck struct {
+	Enabled       bool          `json:"enabled" yaml:"enabled"`
+	DeferInterval time.Duration `json:"deferInterval" yaml:"deferInterval"`
+}
+
+// EvictionTier selects pods that are evicted in the same wave. A tier without
+// selectors collects every pod no other tier selected.
+type EvictionTier struct {
+	Name               string   `json:"name" yaml:"name"`
+	PriorityClassNames []string `json:"priorityClassNames" yaml:"priorityClassNames"`
+	MinPriority        *int32   `json:"minPriority" yaml:"minPriority"`
+	MaxPriority        *int32   `json:"maxPriority" yaml:"maxPriority"`
+	OwnerKinds         []string `json:"ownerKinds" yaml:"ownerKinds"`
+}
+
+// EvictionRetry configures exponential backoff for evictions rejected by a PodDisruptionBudget
+type EvictionRetry struct {
+	InitialBackoff time.Duration `json:"initialBackoff" yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `json:"maxBackoff" yaml:"maxBackoff"`
+	Factor         float64       `json:"factor" yaml:"factor"`
+}
+
+// HookConfig configures an HTTP hook called during drains. The hook receives
+// a JSON payload; 409 Conflict vetoes the drain and 429 Too Many Requests
+// delays it by the Retry-After header. Stages are preCordon, postCordon,
+// postDrain and onFailure.
+type HookConfig struct {
+	Name          string        `json:"name" yaml:"name"`
+	URL           string        `json:"url" yaml:"url"`
+	Stages        []string      `json:"stages" yaml:"stages"`
+	Timeout       time.Duration `json:"timeout" yaml:"timeout"`
+	Retries       int           `json:"retries" yaml:"retries"`
+	RetryInterval time.Duration `json:"retryInterval" yaml:"retryInterval"`
 }
 
 // APIConfig configures the REST API
@@ -55,6 +197,7 @@ type Config struct {
 	ExcludeLabels  []LabelTrigger  `json:"excludeLabels" yaml:"excludeLabels"`
 	NodeConditions []NodeCondition `json:"nodeConditions" yaml:"nodeConditions"`
 	DrainSettings  DrainSettings   `json:"drainSettings" yaml:"drainSettings"`
+	Hooks          []HookConfig    `json:"hooks" yaml:"hooks"`
 	API            APIConfig       `json:"api" yaml:"api"`
 	Metrics        MetricsConfig   `json:"metrics" yaml:"metrics"`
 	DryRun         bool            `json:"dryRun" yaml:"dryRun"`